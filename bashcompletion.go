@@ -0,0 +1,130 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteBashCompletion writes on w a bash completion script for the given
+// parser, which must have been created using NewParser or MustNewParser.
+func WriteBashCompletion(w io.Writer, parser Parser) error {
+	root, err := newParserCompletionTree(parser)
+	if err != nil {
+		return err
+	}
+	return writeBashCompletion(w, root)
+}
+
+// WriteBashCompletion writes on w a bash completion script for the whole
+// commands tree. The script completes subcommand names level by level as
+// well as the options valid at the current level, including the persistent
+// options of the ancestor levels, and stops completing options after `--`.
+// The script completes the values of the options implementing Completer
+// by running `prog __complete`. You should call this method on the
+// toplevel command. To use the script, source it from bash.
+func (p *CommandParser) WriteBashCompletion(w io.Writer) error {
+	root, err := p.newCompletionTree()
+	if err != nil {
+		return err
+	}
+	return writeBashCompletion(w, root)
+}
+
+// writeBashCompletion writes the bash completion script for the given tree.
+func writeBashCompletion(w io.Writer, root *completionNode) error {
+	nodes := root.flatten()
+	function := "_" + shellIdentifier(root.name()) + "_complete"
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# bash completion for %s\n\n", root.name())
+	fmt.Fprintf(&sb, "%s() {\n", function)
+	sb.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&sb, "\tlocal path=%s dashdash=0 skip=0 owner=\"\" i word\n", shellQuote(root.path()))
+
+	// 1. walk the words before the current one to figure out which is
+	// the current command, skipping the values of options.
+	sb.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	sb.WriteString("\t\tword=\"${COMP_WORDS[i]}\"\n")
+	sb.WriteString("\t\tif ((skip)); then\n\t\t\tskip=0\n\t\t\tcontinue\n\t\tfi\n")
+	sb.WriteString("\t\tif ((!dashdash)); then\n")
+	sb.WriteString("\t\t\tcase \"$word\" in\n")
+	sb.WriteString("\t\t\t--)\n\t\t\t\tdashdash=1\n\t\t\t\tcontinue\n\t\t\t\t;;\n")
+	sb.WriteString("\t\t\t-*)\n")
+	sb.WriteString("\t\t\t\tcase \"$path $word\" in\n")
+	var patterns []string
+	for _, node := range nodes {
+		for _, word := range valueOptionWords(node.allOptions()) {
+			patterns = append(patterns, shellQuote(node.path()+" "+word))
+		}
+	}
+	if len(patterns) > 0 {
+		fmt.Fprintf(&sb, "\t\t\t\t%s)\n\t\t\t\t\tskip=1\n\t\t\t\t\towner=\"$path $word\"\n\t\t\t\t\t;;\n",
+			strings.Join(patterns, "|"))
+	}
+	sb.WriteString("\t\t\t\tesac\n")
+	sb.WriteString("\t\t\t\tcontinue\n")
+	sb.WriteString("\t\t\t\t;;\n")
+	sb.WriteString("\t\t\tesac\n")
+	sb.WriteString("\t\tfi\n")
+	sb.WriteString("\t\tcase \"$path $word\" in\n")
+	for _, node := range nodes {
+		for _, child := range node.children {
+			var patterns []string
+			for _, path := range child.aliasPaths() {
+				patterns = append(patterns, shellQuote(path))
+			}
+			fmt.Fprintf(&sb, "\t\t%s)\n\t\t\tpath=%s\n\t\t\tdashdash=0\n\t\t\t;;\n",
+				strings.Join(patterns, "|"), shellQuote(child.path()))
+		}
+	}
+	sb.WriteString("\t\tesac\n")
+	sb.WriteString("\tdone\n")
+
+	// 2. use `prog __complete` to complete the values of the options
+	// implementing Completer, whose values are only known at runtime.
+	var dynamic []string
+	for _, node := range nodes {
+		for _, word := range node.completerOptionWords() {
+			dynamic = append(dynamic, shellQuote(node.path()+" "+word))
+		}
+	}
+	if len(dynamic) > 0 {
+		sb.WriteString("\tif ((skip)); then\n")
+		sb.WriteString("\t\tcase \"$owner\" in\n")
+		fmt.Fprintf(&sb, "\t\t%s)\n", strings.Join(dynamic, "|"))
+		sb.WriteString("\t\t\tlocal IFS=$'\\n'\n")
+		sb.WriteString("\t\t\tCOMPREPLY=($(\"${COMP_WORDS[0]}\" __complete \"${COMP_WORDS[@]:1:COMP_CWORD}\" 2>/dev/null))\n")
+		sb.WriteString("\t\t\treturn\n")
+		sb.WriteString("\t\t\t;;\n")
+		sb.WriteString("\t\tesac\n")
+		sb.WriteString("\tfi\n")
+	}
+
+	// 3. determine the options and the subcommands of the current command.
+	sb.WriteString("\tlocal options=\"\" subcommands=\"\"\n")
+	sb.WriteString("\tcase \"$path\" in\n")
+	for _, node := range nodes {
+		var subcommands []string
+		for _, child := range node.children {
+			subcommands = append(subcommands, child.name())
+		}
+		fmt.Fprintf(&sb, "\t%s)\n", shellQuote(node.path()))
+		fmt.Fprintf(&sb, "\t\toptions=%s\n", shellQuote(strings.Join(optionWords(node.allOptions()), " ")))
+		fmt.Fprintf(&sb, "\t\tsubcommands=%s\n", shellQuote(strings.Join(subcommands, " ")))
+		sb.WriteString("\t\t;;\n")
+	}
+	sb.WriteString("\tesac\n")
+
+	// 4. complete either options or subcommands.
+	sb.WriteString("\tif ((!dashdash)) && [[ \"$cur\" == -* ]]; then\n")
+	sb.WriteString("\t\tCOMPREPLY=($(compgen -W \"$options\" -- \"$cur\"))\n")
+	sb.WriteString("\t\treturn\n")
+	sb.WriteString("\tfi\n")
+	sb.WriteString("\tCOMPREPLY=($(compgen -W \"$subcommands\" -- \"$cur\"))\n")
+	sb.WriteString("}\n\n")
+
+	fmt.Fprintf(&sb, "complete -o default -F %s %s\n", function, shellQuote(root.name()))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
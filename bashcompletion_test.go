@@ -0,0 +1,124 @@
+package getoptx
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runBashCompletion sources the given bash completion script, invokes the
+// completion function with the given words, where the last word is the one
+// being completed, and returns the candidates. We skip the current test if
+// bash is not installed.
+func runBashCompletion(t *testing.T, script, function string, words ...string) []string {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	filename := filepath.Join(t.TempDir(), "completion.bash")
+	if err := os.WriteFile(filename, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var quoted []string
+	for _, word := range words {
+		quoted = append(quoted, shellQuote(word))
+	}
+	driver := "source " + shellQuote(filename) + "\n" +
+		"COMP_WORDS=(" + strings.Join(quoted, " ") + ")\n" +
+		"COMP_CWORD=$((${#COMP_WORDS[@]} - 1))\n" +
+		function + "\n" +
+		"printf '%s\\n' \"${COMPREPLY[@]}\"\n"
+	output, err := exec.Command(bash, "--norc", "--noprofile", "-c", driver).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(output))
+}
+
+func TestWriteBashCompletion(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	var sb bytes.Buffer
+	if err := cli.WriteBashCompletion(&sb); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+	if !strings.HasSuffix(script, "complete -o default -F _prog_complete 'prog'\n") {
+		t.Fatalf("unexpected script:\n%s", script)
+	}
+
+	type testcase struct {
+		name   string
+		words  []string
+		expect []string
+	}
+
+	testcases := []testcase{{
+		name:   "toplevel subcommands",
+		words:  []string{"prog", ""},
+		expect: []string{"list", "run"},
+	}, {
+		name:   "toplevel options",
+		words:  []string{"prog", "--"},
+		expect: []string{"--batch", "--help", "--logfile", "--verbose"},
+	}, {
+		name:   "nested subcommands",
+		words:  []string{"prog", "-v", "run", "w"},
+		expect: []string{"websites"},
+	}, {
+		name:   "skip option values",
+		words:  []string{"prog", "--logfile", "run", ""},
+		expect: []string{"list", "run"},
+	}, {
+		name:   "leaf options including the persistent ones",
+		words:  []string{"prog", "run", "websites", "--"},
+		expect: []string{"--input", "--logfile", "--verbose", "--force-http-3", "--help"},
+	}, {
+		name:   "no options after dash dash",
+		words:  []string{"prog", "run", "--", "-"},
+		expect: []string{},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := runBashCompletion(t, script, "_prog_complete", tc.words...)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Fatalf("expected %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestWriteBashCompletionParser(t *testing.T) {
+	var options struct {
+		Input   string `doc:"add URL to measure"`
+		Verbose bool   `doc:"run in verbose mode" short:"v"`
+	}
+	parser, err := NewParser(&options, SetProgramName("/usr/bin/my-prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteBashCompletion(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	got := runBashCompletion(t, sb.String(), "_my_prog_complete", "my-prog", "-")
+	if expect := []string{"--input", "--verbose", "-v"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+
+	var notOurs fakeParser
+	if err := WriteBashCompletion(&sb, notOurs); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// fakeParser is a Parser not created using NewParser.
+type fakeParser struct {
+	Parser
+}
@@ -0,0 +1,320 @@
+package getoptx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// Catalog is a message catalog containing the translation of the messages
+// we print to the user (e.g., "Usage:"). Each message is a format string for
+// fmt.Sprintf identified by a key (e.g., MsgUsage) that takes the same
+// arguments, in the same order, of the corresponding English message. When
+// a message is missing from a catalog, we fall back to English.
+//
+// The `doc:"..."` tag of an option and the description of a command may also
+// refer to a message in the catalog using the `msgid:` prefix followed by
+// the message key (e.g., `doc:"msgid:run.input"`). In such a case, you
+// should add this key to every catalog, including an English one, since
+// we fall back to printing the key itself when we cannot find it.
+type Catalog interface {
+	// Lookup returns the message with the given key and true, if the
+	// catalog contains such a message, or false otherwise.
+	Lookup(key string) (string, bool)
+}
+
+// MapCatalog is a Catalog implemented using a map.
+type MapCatalog map[string]string
+
+var _ Catalog = MapCatalog{}
+
+// Lookup implements Catalog.Lookup.
+func (c MapCatalog) Lookup(key string) (string, bool) {
+	message, found := c[key]
+	return message, found
+}
+
+// The keys of the messages we print. The comment next to each key
+// shows the corresponding English message.
+const (
+	MsgUsage               = "usage"                // "Usage:"
+	MsgOptions             = "options"              // "Options"
+	MsgSectionOptions      = "section-options"      // "%s options"
+	MsgOptionsFor          = "options-for"          // "%s for %s:"
+	MsgSubcommands         = "subcommands"          // "Subcommands:"
+	MsgSubcommandsHint     = "subcommands-hint"     // "Run '%s <subcommand>' for more information."
+	MsgDescription         = "description"          // "Description:"
+	MsgExamples            = "examples"             // "Examples:"
+	MsgMandatory           = "mandatory"            // "This option is mandatory."
+	MsgHelpOption          = "help-option"          // "Prints this help message"
+	MsgHelpCommand         = "help-command"         // "Prints generic or command-specific help"
+	MsgHelpAllOption       = "help-all-option"      // "Prints this help message including hidden subcommands"
+	MsgCompleteCommand     = "complete-command"     // "Prints completions for a partial command line"
+	MsgTimeoutOption       = "timeout-option"       // "Stops the command after the given duration (e.g., 30s)"
+	MsgInterrupted         = "interrupted"          // "received %s, stopping (send it again to exit immediately)"
+	MsgError               = "error"                // "error:"
+	MsgSeeHelp             = "see-help"             // "%s. See '%s --help'."
+	MsgForCommand          = "for-command"          // "for command %s: %s"
+	MsgInternalError       = "internal-error"       // "internal error: %s"
+	MsgExpectedSubcommand  = "expected-subcommand"  // "expected subcommand name"
+	MsgUnknownOption       = "unknown-option"       // "unknown option: %s"
+	MsgMissingValue        = "missing-value"        // "missing value for %s"
+	MsgUnexpectedValue     = "unexpected-value"     // "unexpected value for %s: '%s'"
+	MsgInvalidValue        = "invalid-value"        // "invalid value for %s: '%s'"
+	MsgMissingRequired     = "missing-required"     // "option %s is mandatory"
+	MsgNoSuchSubcommand    = "no-such-subcommand"   // "no such subcommand: '%s'"
+	MsgAmbiguousOption     = "ambiguous-option"     // "ambiguous option: %s (could be %s)"
+	MsgAmbiguousSubcommand = "ambiguous-subcommand" // "ambiguous subcommand: '%s' (could be %s)"
+	MsgTooFewPositionals   = "too-few-positionals"  // "too few positional arguments"
+	MsgTooManyPositionals  = "too-many-positionals" // "too many positional arguments"
+	MsgDidYouMean          = "did-you-mean"         // "Did you mean '%s'?"
+	MsgDidYouMeanOneOf     = "did-you-mean-one-of"  // "Did you mean one of %s?"
+	MsgValidSubcommands    = "valid-subcommands"    // "Valid subcommands are: %s."
+)
+
+// The keys of the messages describing the errors caused by invalid
+// options structures, which we print as internal errors.
+const (
+	MsgExpectedPointer         = "expected-pointer"           // "expected a pointer"
+	MsgExpectedPointerToStruct = "expected-pointer-to-struct" // "expected a pointer to struct"
+	MsgUnaddressableField      = "unaddressable-field"        // "cannot obtain the address of a field"
+	MsgUndocumentedField       = "undocumented-field"         // "there is a field without documentation"
+	MsgInvalidShortTag         = "invalid-short-tag"          // "the short tag's value must contain a single-byte string"
+	MsgPrivateField            = "private-field"              // "a field inside the structure is private"
+)
+
+// englishCatalog is the default catalog.
+var englishCatalog = MapCatalog{
+	MsgUsage:               "Usage:",
+	MsgOptions:             "Options",
+	MsgSectionOptions:      "%s options",
+	MsgOptionsFor:          "%s for %s:",
+	MsgSubcommands:         "Subcommands:",
+	MsgSubcommandsHint:     "Run '%s <subcommand>' for more information.",
+	MsgDescription:         "Description:",
+	MsgExamples:            "Examples:",
+	MsgMandatory:           "This option is mandatory.",
+	MsgHelpOption:          "Prints this help message",
+	MsgHelpCommand:         "Prints generic or command-specific help",
+	MsgHelpAllOption:       "Prints this help message including hidden subcommands",
+	MsgCompleteCommand:     "Prints completions for a partial command line",
+	MsgTimeoutOption:       "Stops the command after the given duration (e.g., 30s)",
+	MsgInterrupted:         "received %s, stopping (send it again to exit immediately)",
+	MsgError:               "error:",
+	MsgSeeHelp:             "%s. See '%s --help'.",
+	MsgForCommand:          "for command %s: %s",
+	MsgInternalError:       "internal error: %s",
+	MsgExpectedSubcommand:  "expected subcommand name",
+	MsgUnknownOption:       "unknown option: %s",
+	MsgMissingValue:        "missing value for %s",
+	MsgUnexpectedValue:     "unexpected value for %s: '%s'",
+	MsgInvalidValue:        "invalid value for %s: '%s'",
+	MsgMissingRequired:     "option %s is mandatory",
+	MsgNoSuchSubcommand:    "no such subcommand: '%s'",
+	MsgAmbiguousOption:     "ambiguous option: %s (could be %s)",
+	MsgAmbiguousSubcommand: "ambiguous subcommand: '%s' (could be %s)",
+	MsgTooFewPositionals:   "too few positional arguments",
+	MsgTooManyPositionals:  "too many positional arguments",
+	MsgDidYouMean:          "Did you mean '%s'?",
+	MsgDidYouMeanOneOf:     "Did you mean one of %s?",
+	MsgValidSubcommands:    "Valid subcommands are: %s.",
+
+	MsgExpectedPointer:         "expected a pointer",
+	MsgExpectedPointerToStruct: "expected a pointer to struct",
+	MsgUnaddressableField:      "cannot obtain the address of a field",
+	MsgUndocumentedField:       "there is a field without documentation",
+	MsgInvalidShortTag:         "the short tag's value must contain a single-byte string",
+	MsgPrivateField:            "a field inside the structure is private",
+}
+
+// EnglishCatalog returns a copy of the default English catalog, which
+// is useful as a starting point for writing a new translation.
+func EnglishCatalog() MapCatalog {
+	out := make(MapCatalog)
+	for key, message := range englishCatalog {
+		out[key] = message
+	}
+	return out
+}
+
+var (
+	// catalogsMu protects catalogs.
+	catalogsMu sync.Mutex
+
+	// catalogs contains the catalogs registered using RegisterCatalog.
+	catalogs = make(map[string]Catalog)
+)
+
+// RegisterCatalog registers the catalog for the given language, which is
+// either a language code (e.g., "it") or a language code followed by a
+// territory (e.g., "pt_BR"). Unless you use SetCatalog, we select the
+// catalog to use according to the LC_ALL, LC_MESSAGES, and LANG environment
+// variables, in this order. For example, given `LANG=pt_BR.UTF-8`, we first
+// search for "pt_BR" and then for "pt". When there is no catalog for the
+// current language, we use English. You typically want to register all your
+// catalogs from an init function, before constructing any parser.
+func RegisterCatalog(language string, catalog Catalog) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	catalogs[language] = catalog
+}
+
+// catalogFromEnvironment returns the registered catalog matching the language
+// configured by the environment variables or nil if there is none.
+func catalogFromEnvironment() Catalog {
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	// Remove the codeset and the modifier (e.g., "it_IT.UTF-8@euro").
+	if idx := strings.IndexAny(locale, ".@"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	if catalog, found := catalogs[locale]; found && locale != "" {
+		return catalog
+	}
+	if idx := strings.Index(locale, "_"); idx > 0 {
+		return catalogs[locale[:idx]]
+	}
+	return nil
+}
+
+// SetCatalog is a bit of config that sets the message catalog, thus
+// overriding the catalog we would otherwise select according to the
+// environment variables (see RegisterCatalog). When using a command,
+// you should pass this config to CommandParser.Configure.
+func SetCatalog(catalog Catalog) Config {
+	return &setCatalog{catalog: catalog}
+}
+
+type setCatalog struct {
+	catalog Catalog
+}
+
+func (c *setCatalog) visit(p *parserWrapper) {
+	p.catalog = c.catalog
+}
+
+// messages formats the messages we print using a catalog.
+type messages struct {
+	// catalog is the catalog, which may be nil.
+	catalog Catalog
+}
+
+// newMessages creates a new messages instance using the given catalog or,
+// if the catalog is nil, the catalog selected by the environment.
+func newMessages(catalog Catalog) *messages {
+	if catalog == nil {
+		catalog = catalogFromEnvironment()
+	}
+	return &messages{catalog: catalog}
+}
+
+// messagesFromConfigs is like newMessages but uses the catalog set
+// by the last SetCatalog config inside configs, if any.
+func messagesFromConfigs(configs []Config) *messages {
+	var catalog Catalog
+	for _, config := range configs {
+		if c, okay := config.(*setCatalog); okay {
+			catalog = c.catalog
+		}
+	}
+	return newMessages(catalog)
+}
+
+// get returns the message with the given key.
+func (m *messages) get(key string) string {
+	if m.catalog != nil {
+		if message, found := m.catalog.Lookup(key); found {
+			return message
+		}
+	}
+	if message, found := englishCatalog.Lookup(key); found {
+		return message
+	}
+	return key
+}
+
+// sprintf formats the message with the given key using the given arguments.
+func (m *messages) sprintf(key string, v ...interface{}) string {
+	return fmt.Sprintf(m.get(key), v...)
+}
+
+// resolve returns the message referenced by text if text starts with the
+// `msgid:` prefix. Otherwise, it returns text unmodified.
+func (m *messages) resolve(text string) string {
+	if strings.HasPrefix(text, "msgid:") {
+		return m.get(strings.TrimPrefix(text, "msgid:"))
+	}
+	return text
+}
+
+// describeError returns the translated description of the given error. For
+// the errors we do not know how to translate, such as the ones returned by
+// the validators of positional arguments, we resolve err.Error() as if it
+// were a doc tag, hence a `msgid:` prefix refers to a catalog message.
+func (m *messages) describeError(err error) string {
+	var (
+		uoe *UnknownOptionError
+		mre *MissingRequiredError
+		ive *InvalidValueError
+		use *UnknownSubcommandError
+		aoe *AmbiguousOptionError
+		ase *AmbiguousSubcommandError
+		ce  *catalogError
+	)
+	switch {
+	case errors.As(err, &uoe):
+		return m.sprintf(MsgUnknownOption, uoe.Option)
+	case errors.As(err, &mre):
+		return m.sprintf(MsgMissingRequired, mre.Option)
+	case errors.As(err, &ive):
+		return m.describeInvalidValue(ive)
+	case errors.As(err, &use):
+		return m.sprintf(MsgNoSuchSubcommand, use.Name)
+	case errors.As(err, &aoe):
+		return m.sprintf(MsgAmbiguousOption, aoe.Option, quoteAndJoin(aoe.Candidates))
+	case errors.As(err, &ase):
+		return m.sprintf(MsgAmbiguousSubcommand, ase.Name, quoteAndJoin(ase.Candidates))
+	case errors.Is(err, ErrTooFewPositionalArguments):
+		return m.get(MsgTooFewPositionals)
+	case errors.Is(err, ErrTooManyPositionalArguments):
+		return m.get(MsgTooManyPositionals)
+	case errors.As(err, &ce):
+		return m.get(ce.key)
+	default:
+		return m.resolve(err.Error())
+	}
+}
+
+// describeInvalidValue returns the translated description of an
+// InvalidValueError, which depends on pborman's error code.
+func (m *messages) describeInvalidValue(ive *InvalidValueError) string {
+	switch ive.code {
+	case getopt.MissingParameter:
+		return m.sprintf(MsgMissingValue, ive.Option)
+	case getopt.ExtraParameter:
+		return m.sprintf(MsgUnexpectedValue, ive.Option, ive.Value)
+	default:
+		return m.sprintf(MsgInvalidValue, ive.Option, ive.Value)
+	}
+}
+
+// catalogError is an error whose message is in the catalog.
+type catalogError struct {
+	// key is the message key.
+	key string
+}
+
+// Error implements error.
+func (e *catalogError) Error() string {
+	return englishCatalog[e.key]
+}
@@ -0,0 +1,213 @@
+package getoptx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// italianTestCatalog is a partial Italian catalog for testing.
+var italianTestCatalog = MapCatalog{
+	MsgUsage:           "Uso:",
+	MsgHelpOption:      "Stampa questo messaggio di aiuto",
+	MsgMissingValue:    "manca il valore di %s",
+	MsgUnexpectedValue: "valore inatteso per %s: '%s'",
+	MsgInvalidValue:    "valore non valido per %s: '%s'",
+	MsgExpectedPointer: "atteso un puntatore",
+	"list.invalid-id":  "ID non valido",
+}
+
+func TestCatalogDescribeError(t *testing.T) {
+	type options struct {
+		ID      int  `doc:"the ID"`
+		Verbose bool `doc:"verbose mode"`
+	}
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect string
+	}
+
+	testcases := []testcase{{
+		name:   "missing value",
+		argv:   []string{"prog", "--id"},
+		expect: "manca il valore di --id",
+	}, {
+		name:   "invalid value",
+		argv:   []string{"prog", "--id", "zz"},
+		expect: "valore non valido per --id: 'zz'",
+	}, {
+		name:   "invalid flag value",
+		argv:   []string{"prog", "--verbose=zz"},
+		expect: "valore non valido per --verbose: 'zz'",
+	}, {
+		name:   "fallback to English",
+		argv:   []string{"prog", "--nonexistent"},
+		expect: "unknown option: --nonexistent",
+	}, {
+		name:   "validator using msgid",
+		argv:   []string{"prog", "a"},
+		expect: "ID non valido",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts options
+			parser, err := NewParser(&opts, SetCatalog(italianTestCatalog),
+				ValidatePositionalArguments(func(args []string) error {
+					return errors.New("msgid:list.invalid-id")
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = parser.Getopt(tc.argv)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			got := newMessages(italianTestCatalog).describeError(err)
+			if got != tc.expect {
+				t.Fatalf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestCatalogUnexpectedValue(t *testing.T) {
+	// pborman's parser does not currently emit this error code, yet
+	// we translate it, so make sure we're doing that correctly.
+	err := &InvalidValueError{
+		Option: "--verbose",
+		Value:  "zz",
+		Err:    errors.New("unexpected parameter passed to --verbose: \"zz\""),
+		code:   getopt.ExtraParameter,
+	}
+	if got := newMessages(italianTestCatalog).describeError(err); got != "valore inatteso per --verbose: 'zz'" {
+		t.Fatalf("unexpected translation: %q", got)
+	}
+}
+
+func TestCatalogInternalErrors(t *testing.T) {
+	_, err := NewParser(struct{}{})
+	if err == nil || err.Error() != "expected a pointer" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := newMessages(italianTestCatalog).describeError(err); got != "atteso un puntatore" {
+		t.Fatalf("unexpected translation: %q", got)
+	}
+}
+
+func TestCatalogFromEnvironment(t *testing.T) {
+	RegisterCatalog("it", italianTestCatalog)
+	t.Cleanup(func() {
+		catalogsMu.Lock()
+		delete(catalogs, "it")
+		catalogsMu.Unlock()
+	})
+
+	type testcase struct {
+		name   string
+		env    map[string]string
+		expect string
+	}
+
+	testcases := []testcase{{
+		name:   "LANG with territory and codeset",
+		env:    map[string]string{"LC_ALL": "", "LC_MESSAGES": "", "LANG": "it_IT.UTF-8"},
+		expect: "Uso:",
+	}, {
+		name:   "LC_ALL takes precedence",
+		env:    map[string]string{"LC_ALL": "C", "LC_MESSAGES": "", "LANG": "it_IT.UTF-8"},
+		expect: "Usage:",
+	}, {
+		name:   "LC_MESSAGES takes precedence over LANG",
+		env:    map[string]string{"LC_ALL": "", "LC_MESSAGES": "it", "LANG": "C"},
+		expect: "Uso:",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			if got := newMessages(nil).get(MsgUsage); got != tc.expect {
+				t.Fatalf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestCatalogBuiltinDocs(t *testing.T) {
+	var options struct {
+		Verbose bool `doc:"verbose mode"`
+	}
+	cli := Command("Test program", &options)
+	cli.Configure(SetCatalog(italianTestCatalog))
+
+	parser, _, err := cli.newParserWrapper([]*CommandParser{cli})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, o := range parser.helpOptions() {
+		if o.Long == "help" {
+			found = true
+			if o.Doc != "Stampa questo messaggio di aiuto" {
+				t.Fatalf("unexpected --help doc: %q", o.Doc)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("cannot find --help")
+	}
+
+	for _, sc := range cli.subcommands {
+		if sc.name == "__complete" && sc.description != "msgid:"+MsgCompleteCommand {
+			t.Fatalf("unexpected __complete description: %q", sc.description)
+		}
+	}
+}
+
+func TestCatalogHelp(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+	stdout := captureStdout(t)
+	var options struct {
+		Input string `doc:"msgid:input-doc" required:"true"`
+	}
+	catalog := MapCatalog{
+		MsgUsage:       "Uso:",
+		MsgOptions:     "Opzioni",
+		MsgOptionsFor:  "%s per %s:",
+		MsgSubcommands: "Sottocomandi:",
+		MsgMandatory:   "Questa opzione è obbligatoria.",
+		MsgHelpCommand: "Stampa l'aiuto",
+		"input-doc":    "aggiunge un URL da misurare",
+		"program-doc":  "Strumento di misura",
+	}
+	cli := Command("msgid:program-doc", &options)
+	cli.Configure(SetCatalog(catalog))
+	if _, err := cli.Getopt([]string{"prog", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `
+Uso: prog [options] --input string <subcommand> [...]
+
+Strumento di misura.
+
+Opzioni per prog:
+
+      --input string
+             aggiunge un URL da misurare. Questa opzione è obbligatoria.
+
+Sottocomandi:
+
+  help
+             Stampa l'aiuto.
+
+`
+	if got := stdout(); got != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, got)
+	}
+}
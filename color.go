@@ -0,0 +1,94 @@
+package getoptx
+
+import (
+	"io"
+	"os"
+)
+
+// ColorMode controls whether we use ANSI styles (i.e., bold headings,
+// highlighted options and subcommands, and red error prefixes) when
+// printing help messages and errors.
+type ColorMode int
+
+const (
+	// ColorAuto uses ANSI styles only when writing to a terminal. Setting
+	// the NO_COLOR environment variable to a nonempty value disables ANSI
+	// styles, while setting CLICOLOR_FORCE to a value other than "0" enables
+	// them also when not writing to a terminal. This is the default.
+	ColorAuto = ColorMode(iota)
+
+	// ColorAlways always uses ANSI styles.
+	ColorAlways
+
+	// ColorNever never uses ANSI styles.
+	ColorNever
+)
+
+// SetColorMode is a bit of config that controls whether to use ANSI
+// styles when printing help messages and errors. The default is ColorAuto.
+func SetColorMode(mode ColorMode) Config {
+	return &setColorMode{mode: mode}
+}
+
+type setColorMode struct {
+	mode ColorMode
+}
+
+func (c *setColorMode) visit(p *parserWrapper) {
+	p.layout.color = c.mode
+}
+
+// enabled returns whether we should use ANSI styles when writing on w.
+func (mode ColorMode) enabled(w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	f, okay := w.(*os.File)
+	return okay && terminalWidth(f) > 0
+}
+
+// The ANSI styles we use.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiCyan  = "\x1b[36m"
+	ansiRed   = "\x1b[1;31m"
+	ansiReset = "\x1b[0m"
+)
+
+// stylize returns s wrapped by the given ANSI style, if enabled,
+// and otherwise returns s unmodified.
+func stylize(enabled bool, style, s string) string {
+	if !enabled || s == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// errorPrefix returns the given error prefix (e.g., "error:"),
+// styled in red if we should use ANSI styles when writing on w.
+func (p *parserWrapper) errorPrefix(w io.Writer, prefix string) string {
+	return stylize(p.layout.color.enabled(w), ansiRed, prefix)
+}
+
+// errorPrefix is like parserWrapper.errorPrefix but uses the ColorMode
+// configured using Configure, hence we can use it before we have created
+// a parser. You should call this method on the toplevel command, since
+// we use the toplevel configuration for the whole commands tree.
+func (p *CommandParser) errorPrefix(w io.Writer, prefix string) string {
+	mode := ColorAuto
+	for _, config := range p.configs {
+		if c, okay := config.(*setColorMode); okay {
+			mode = c.mode // the last one wins, as with parsers
+		}
+	}
+	return stylize(mode.enabled(w), ansiRed, prefix)
+}
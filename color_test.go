@@ -0,0 +1,144 @@
+package getoptx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorErrorPrefix(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type testcase struct {
+		name    string
+		options interface{}
+		argv    []string
+		mode    ColorMode
+		expect  string
+	}
+
+	var options struct {
+		Verbose bool `doc:"run in verbose mode"`
+	}
+
+	testcases := []testcase{{
+		name:    "parse error",
+		options: &options,
+		argv:    []string{"prog", "--nonexistent"},
+		mode:    ColorAlways,
+		expect:  ansiRed + "prog:" + ansiReset + " unknown option: --nonexistent",
+	}, {
+		name:    "internal error",
+		options: options,
+		argv:    []string{"prog"},
+		mode:    ColorAlways,
+		expect:  ansiRed + "prog:" + ansiReset + " internal error: expected a pointer",
+	}, {
+		name:    "internal error without colors",
+		options: options,
+		argv:    []string{"prog"},
+		mode:    ColorNever,
+		expect:  "prog: internal error: expected a pointer",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := captureStderr(t)
+			cli := Command("Test program", tc.options)
+			cli.Configure(SetColorMode(tc.mode))
+			if _, err := cli.Getopt(tc.argv); err == nil {
+				t.Fatal("expected an error")
+			}
+			if output := stderr(); !strings.HasPrefix(output, tc.expect) {
+				t.Fatalf("expected %q, got %q", tc.expect, output)
+			}
+		})
+	}
+}
+
+func TestColorModeEnabled(t *testing.T) {
+	type testcase struct {
+		name          string
+		mode          ColorMode
+		noColor       string
+		clicolorForce string
+		expect        bool
+	}
+
+	testcases := []testcase{{
+		name:   "always",
+		mode:   ColorAlways,
+		expect: true,
+	}, {
+		name:    "always wins over NO_COLOR",
+		mode:    ColorAlways,
+		noColor: "1",
+		expect:  true,
+	}, {
+		name:          "never wins over CLICOLOR_FORCE",
+		mode:          ColorNever,
+		clicolorForce: "1",
+		expect:        false,
+	}, {
+		name:   "auto without a terminal",
+		mode:   ColorAuto,
+		expect: false,
+	}, {
+		name:          "auto with CLICOLOR_FORCE",
+		mode:          ColorAuto,
+		clicolorForce: "1",
+		expect:        true,
+	}, {
+		name:          "auto with CLICOLOR_FORCE=0",
+		mode:          ColorAuto,
+		clicolorForce: "0",
+		expect:        false,
+	}, {
+		name:          "NO_COLOR wins over CLICOLOR_FORCE",
+		mode:          ColorAuto,
+		noColor:       "1",
+		clicolorForce: "1",
+		expect:        false,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tc.noColor)
+			t.Setenv("CLICOLOR_FORCE", tc.clicolorForce)
+			var sb strings.Builder
+			if got := tc.mode.enabled(&sb); got != tc.expect {
+				t.Fatalf("expected %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestColorHelp(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	var options struct {
+		Verbose bool `doc:"run in verbose mode" short:"v"`
+	}
+
+	parser, err := NewParser(&options, SetProgramName("prog"), SetColorMode(ColorAlways))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	parser.PrintUsage(&sb)
+	expect := "\n" + ansiBold + "Usage:" + ansiReset + " prog [options] [parameters ...]\n\n" +
+		ansiBold + "Options:" + ansiReset + "\n\n" +
+		"  " + ansiCyan + "-v" + ansiReset + ", " + ansiCyan + "--verbose" + ansiReset + "\n" +
+		"             run in verbose mode.\n\n"
+	if sb.String() != expect {
+		t.Fatalf("expected %q, got %q", expect, sb.String())
+	}
+
+	parser, err = NewParser(&options, SetProgramName("prog"), SetColorMode(ColorNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Reset()
+	parser.PrintUsage(&sb)
+	if strings.Contains(sb.String(), "\x1b[") {
+		t.Fatalf("unexpected ANSI styles inside %q", sb.String())
+	}
+}
@@ -8,33 +8,46 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
-	"github.com/mitchellh/go-wordwrap"
+	"github.com/pborman/getopt/v2"
 )
 
 // HasPrintedHelp is the fake subcommand returned when CommandParser.Getopt or
-// CommandParser.MustGetopt have printed an help message.
+// CommandParser.MustGetopt have printed an help message or completions.
 type HasPrintedHelp struct{}
 
 // Command creates the toplevel command for the whole program with the given
 // description, the given options, and zero or more subcommands. This function
 // also register an internal subcommand implementing the `help` subcommand
-// unless you have already included one such command in subcommands. Apart from
-// that, this function is equivalent to calling Subcommand with os.Args[0] as
-// the first argument followed by the arguments passed to Command. For this
-// reason, please see Subcommand for more information about the usage.
+// unless you have already included one such command in subcommands. Likewise,
+// this function registers the hidden `__complete` subcommand implementing
+// dynamic completion (see Completer). Apart from that, this function is
+// equivalent to calling Subcommand with os.Args[0] as the first argument
+// followed by the arguments passed to Command. For this reason, please see
+// Subcommand for more information about the usage.
 func Command(
 	description string, options interface{}, subcommands ...*CommandParser) *CommandParser {
 	if !containsHelp(subcommands) {
 		subcommands = append(subcommands, LeafSubcommand(
-			"help", "Prints generic or command-specific help", &subcommandHelp{}))
+			"help", "msgid:"+MsgHelpCommand, &subcommandHelp{}))
+	}
+	if !containsSubcommand(subcommands, "__complete") {
+		complete := LeafSubcommand(
+			"__complete", "msgid:"+MsgCompleteCommand, &subcommandComplete{})
+		complete.hidden = true
+		subcommands = append(subcommands, complete)
 	}
 	return Subcommand(os.Args[0], description, options, subcommands...)
 }
 
 func containsHelp(subcommands []*CommandParser) bool {
+	return containsSubcommand(subcommands, "help")
+}
+
+func containsSubcommand(subcommands []*CommandParser, name string) bool {
 	for _, sc := range subcommands {
-		if sc.name == "help" {
+		if sc.hasName(name) {
 			return true
 		}
 	}
@@ -44,6 +57,9 @@ func containsHelp(subcommands []*CommandParser) bool {
 // subcommandHelp is the internal "help" subcommand.
 type subcommandHelp struct{}
 
+// subcommandComplete is the internal "__complete" subcommand.
+type subcommandComplete struct{}
+
 // Subcommand creates a new subcommand with the given name, the given description,
 // the given options, and zero or more subcommands.
 //
@@ -142,6 +158,10 @@ type subcommandHelp struct{}
 // 3. you can embed options within other options and mark them as `doc:"-"` to
 // force the underlying parser to skip them (since they can't be parsed).
 //
+// As an alternative to type-switching on the selected command's options, you
+// can attach a RunFunc to each command using SetRun and then parse the command
+// line and dispatch to the selected command's RunFunc using Execute.
+//
 // If you want to write a custom `"help"` command, you just need to pass to
 // the toplevel Command call a subcommand implementing `"help"`. In which
 // case, we will not register our internal interceptor for the `"help"` command.
@@ -154,6 +174,7 @@ func Subcommand(name, description string, options interface{},
 		return subcommands[i].name < subcommands[j].name
 	})
 	return &CommandParser{
+		defaults:    optionsDefaults(options),
 		description: description,
 		help:        false,
 		name:        name,
@@ -166,10 +187,12 @@ func Subcommand(name, description string, options interface{},
 // LeafSubcommand creates a subcommand that does not take any further
 // subcommand (i.e., a "leaf" subcommand in the commands tree).
 //
-// You can use Configs such as NoPositionalArguments() to control the leaf
-// subcommand behavior in terms of positional arguments. This function will
-// emit a warning and otherwise ignore any piece of config that does not
-// specifically deal with controlling positional arguments.
+// You can use Configs such as NoPositionalArguments() or
+// ValidatePositionalArguments() to control the leaf subcommand behavior
+// in terms of positional arguments, and PermuteArguments() to allow options
+// after positional arguments. This function will emit a warning and
+// otherwise ignore any piece of config that does not specifically deal
+// with controlling positional arguments.
 //
 // See Subcommand's docs for further information.
 func LeafSubcommand(
@@ -180,6 +203,10 @@ func LeafSubcommand(
 		case *minMaxPositionalArguments:
 			p.pac.minArgs = value.minArgs
 			p.pac.maxArgs = value.maxArgs
+		case *validatePositionalArguments:
+			p.pac.validate = value.fn
+		case *permuteArguments:
+			p.permute = true
 		default:
 			log.Printf("getoptx: ignoring unsupported piece of config: %T %+v", entry, entry)
 		}
@@ -195,25 +222,162 @@ func LeafSubcommand(
 //
 // See Subcommand for more details on the typical usage.
 type CommandParser struct {
+	// aliases contains the alternative names of the command.
+	aliases []string
+
 	// description is the command description.
 	description string
 
+	// epilog is the optional text printed at the end of the help.
+	epilog string
+
+	// examples contains the optional usage examples.
+	examples []HelpExample
+
+	// group is the optional group of the command.
+	group string
+
 	// help allows registering and using -h/--help.
 	help bool
 
+	// helpAll allows registering and using --help-all.
+	helpAll bool
+
+	// helpFormat is the help format requested using --help=FORMAT.
+	helpFormat string
+
+	// hidden indicates that we should not list this command
+	// in the help message and in the generated docs.
+	hidden bool
+
+	// longDescription is the optional long description.
+	longDescription string
+
 	// name is the command name.
 	name string
 
 	// options are the command options.
 	options interface{}
 
+	// configs contains the configs for the whole tree.
+	configs []Config
+
+	// defaults contains the options values before parsing.
+	defaults map[string]string
+
+	// prefixes indicates whether to accept unambiguous prefixes.
+	prefixes bool
+
 	// pac is the positional arguments checker.
 	pac *positionalArgumentsChecker
 
+	// parser is the parser we used when parsing the command line.
+	parser *parserWrapper
+
+	// permute indicates whether to permute arguments.
+	permute bool
+
+	// postRun is the optional hook called after run.
+	postRun RunFunc
+
+	// preRun is the optional hook called before run.
+	preRun RunFunc
+
+	// run is the optional function implementing the command.
+	run RunFunc
+
+	// timeout is the value of the optional --timeout option.
+	timeout time.Duration
+
 	// subcommands contains the subcommands.
 	subcommands []*CommandParser
 }
 
+// SetPrefixMatching controls whether to accept unambiguous prefixes of long
+// options and subcommands (e.g., `ru web --verb` for `run websites --verbose`). An
+// ambiguous prefix causes Getopt to fail listing the possible candidates. This
+// setting is disabled by default. You should call this method on the toplevel
+// command, since we use the toplevel setting for the whole commands tree. We
+// only match hidden commands by their exact name. This method returns the
+// command itself.
+func (p *CommandParser) SetPrefixMatching(enabled bool) *CommandParser {
+	p.prefixes = enabled
+	return p
+}
+
+// SetAliases sets alternative names for this command (e.g., `remove` and `del`
+// for `rm`). The user can select the command using any of its names, and the
+// help message lists the aliases next to the name (e.g., `rm (remove, del)`).
+// Aliases are useful to keep old names working after renaming a command. This
+// method returns the command itself, so that you can write, e.g.:
+//
+//     getoptx.LeafSubcommand("rm", "Removes measurements", &options.Rm).
+//       SetAliases("remove", "del")
+func (p *CommandParser) SetAliases(aliases ...string) *CommandParser {
+	p.aliases = aliases
+	return p
+}
+
+// hasName returns whether name is the command name or one of its aliases.
+func (p *CommandParser) hasName(name string) bool {
+	if p.name == name {
+		return true
+	}
+	for _, alias := range p.aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLongDescription sets the long description of this command, which may
+// contain several paragraphs separated by empty lines. The help message
+// shows the long description in its own section after the options. This
+// method returns the command itself, so that you can write, e.g.:
+//
+//     getoptx.LeafSubcommand("rm", "Removes measurements", &options.Rm).
+//       SetLongDescription("Removes the measurements with the given IDs.").
+//       AddExample("prog rm 1 2", "Removes the measurements with IDs 1 and 2").
+//       SetEpilog("Report bugs to <https://example.com/issues>.")
+func (p *CommandParser) SetLongDescription(text string) *CommandParser {
+	p.longDescription = text
+	return p
+}
+
+// AddExample adds an usage example to this command consisting of a command
+// line and of its explanation. The help message shows the examples in their
+// own section after the options. This method returns the command itself.
+func (p *CommandParser) AddExample(command, explanation string) *CommandParser {
+	p.examples = append(p.examples, HelpExample{Command: command, Explanation: explanation})
+	return p
+}
+
+// SetEpilog sets the text printed at the end of the help message of this
+// command (e.g., "Report bugs to..."), which may contain several paragraphs
+// separated by empty lines. This method returns the command itself.
+func (p *CommandParser) SetEpilog(text string) *CommandParser {
+	p.epilog = text
+	return p
+}
+
+// Configure applies the given Configs (e.g., SetHelpWidth) to the parsers
+// of all the commands in the tree. You should call this method on the toplevel
+// command, since we use the toplevel configuration for the whole commands tree.
+// This method will emit a warning and otherwise ignore any piece of config
+// controlling positional arguments, which you should pass to LeafSubcommand,
+// and AllowPrefixMatching, since you should use SetPrefixMatching.
+func (p *CommandParser) Configure(config ...Config) {
+	for _, entry := range config {
+		switch entry.(type) {
+		case *minMaxPositionalArguments, *validatePositionalArguments, *allowPrefixMatching:
+			log.Printf("getoptx: ignoring unsupported piece of config: %T %+v", entry, entry)
+		default:
+			p.configs = append(p.configs, entry)
+		}
+	}
+}
+
 // SelectedCommand is the type returned by successful parsing of command
 // line arguments by CommandParser.{Must,}Getopt.
 //
@@ -224,6 +388,10 @@ type SelectedCommand struct {
 
 	// args contains the positional arguments.
 	args []string
+
+	// chain contains the commands from the toplevel command to the
+	// selected command. It's empty when we have printed help.
+	chain []*CommandParser
 }
 
 // Args returns the selected command's positional arguments.
@@ -250,7 +418,15 @@ func (p *CommandParser) Getopt(args []string) (*SelectedCommand, error) {
 	if len(args) < 1 {
 		return nil, errors.New("passed a zero length argv")
 	}
-	sc, err := p.getoptall([]*CommandParser{p}, args)
+	// Intercept the internal "__complete" subcommand before parsing, since
+	// the partial command line may contain options that we cannot parse.
+	if len(args) >= 2 && p.isInternalComplete(args[1]) {
+		for _, candidate := range p.complete(args[2:]) {
+			fmt.Fprintf(os.Stdout, "%s\n", candidate)
+		}
+		return &SelectedCommand{options: &HasPrintedHelp{}, args: nil}, nil
+	}
+	sc, err := p.getoptall([]*CommandParser{p}, args, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -281,46 +457,65 @@ func (p *CommandParser) MustGetopt(args []string) *SelectedCommand {
 	return sc
 }
 
-// ErrNoSuchSubcommand indicates that we don't know a subcommand with that name.
+// ErrNoSuchSubcommand indicates that we don't know a subcommand with that name. The
+// actual error returned by Getopt is an *UnknownSubcommandError wrapping this error.
 var ErrNoSuchSubcommand = errors.New("no such subcommand")
 
-// getoptall is the internal worker for Getopt.
-func (p *CommandParser) getoptall(chain []*CommandParser, args []string) (*SelectedCommand, error) {
+// getoptall is the internal worker for Getopt. The offset argument is the
+// index of args[0] inside the original argv passed to Getopt.
+func (p *CommandParser) getoptall(
+	chain []*CommandParser, args []string, offset int) (*SelectedCommand, error) {
 
 	// 0. obtain the command name and crash badly if we have an empty chain
 	if len(chain) < 1 {
 		panic("called with zero length chain")
 	}
 	cmd := chain[0].name
+	m := chain[0].messages()
+	prefix := chain[0].errorPrefix(os.Stderr, cmd+":") // possibly styled in red
 
-	// 1. construct a new parser wrapper with additional support for -h/--help.
+	// 1. reset the state of the previous parse, if any, and construct a
+	// new parser wrapper with additional support for -h/--help.
+	p.resetParseState()
 	parser, fullcmd, err := p.newParserWrapper(chain)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: internal error: %s\n", cmd, err.Error())
+		fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgInternalError, m.describeError(err)))
 		return nil, err
 	}
+	parser.offset = offset
+	p.parser = parser
 
 	// 2. parse command line options using the parser.
 	if err := parser.Getopt(args); err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s. See '%s --help'.\n", cmd, err.Error(), fullcmd)
+		fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgSeeHelp, m.describeError(err), fullcmd))
+		printSuggestions(os.Stderr, err, m)
 		return nil, err
 	}
 
-	// 3. handle the special case of -h/--help.
-	if p.help {
+	// 3. handle the special case of -h/--help, --help=json, and --help-all.
+	if p.help || p.helpAll {
+		if p.helpFormat == "json" {
+			writeJSON(os.Stdout, p.describe(chain))
+			return &SelectedCommand{options: &HasPrintedHelp{}, args: nil}, nil
+		}
 		p.printHelp(parser, os.Stdout, chain)
 		return &SelectedCommand{options: &HasPrintedHelp{}, args: nil}, nil
 	}
 
-	// 4. if there are no subcommands left we've reached a leaf. Check whether there are
-	// any restrictions regarding positional line arguments and otherwise return the selected
+	// 4. if there are no subcommands left we've reached a leaf. Check whether the user has
+	// specified the required options of the whole chain, check whether there are any
+	// restrictions regarding positional line arguments and otherwise return the selected
 	// command with the positional arguments.
 	if len(p.subcommands) <= 0 {
+		if err := p.checkRequired(chain); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgSeeHelp, m.describeError(err), fullcmd))
+			return nil, err
+		}
 		if err := p.pac.check(parser); err != nil {
-			fmt.Fprintf(os.Stderr, "%s: for command %s: %s\n", cmd, p.name, err.Error())
+			fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgForCommand, p.name, m.describeError(err)))
 			return nil, err
 		}
-		return p.newSelectedCommand(parser.Args()), nil
+		return p.newSelectedCommand(chain, parser.Args()), nil
 	}
 
 	// 5. if we expected a subcommand and we didn't find one, then we need to print
@@ -332,26 +527,165 @@ func (p *CommandParser) getoptall(chain []*CommandParser, args []string) (*Selec
 			p.printHelp(parser, os.Stdout, chain)
 			return &SelectedCommand{options: &HasPrintedHelp{}, args: nil}, nil
 		}
-		fmt.Fprintf(os.Stderr,
-			"%s: expected subcommand name. See '%s --help'.\n", cmd, fullcmd)
+		fmt.Fprintf(os.Stderr, "%s %s\n", prefix,
+			m.sprintf(MsgSeeHelp, m.get(MsgExpectedSubcommand), fullcmd))
 		return nil, errors.New("expected subcommand name")
 	}
 
 	// 6. select a subcommand to dispatch to.
 	subcmd := parser.Args()[0]
+	subindex := offset + len(args) - parser.NArgs()
+	matches := withoutInternalComplete(p.matchSubcommand(subcmd, chain[0].prefixes))
+	if len(matches) == 1 {
+		subchain := append([]*CommandParser{}, chain...)
+		subchain = append(subchain, matches[0])
+		return matches[0].getoptall(subchain, parser.Args(), subindex)
+	}
+	if len(matches) > 1 {
+		err = &AmbiguousSubcommandError{
+			Command:    fullcmd,
+			Index:      subindex,
+			Name:       subcmd,
+			Candidates: commandNames(matches),
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgSeeHelp, m.describeError(err), fullcmd))
+		return nil, err
+	}
+
+	// 7. okay we have not found a subcommand, tell the user about this and
+	// suggest similar subcommands or list the valid subcommands.
+	valid := commandNames(p.visibleSubcommands())
+	err = &UnknownSubcommandError{
+		Command:     fullcmd,
+		Index:       subindex,
+		Name:        subcmd,
+		Suggestions: p.suggestSubcommands(subcmd),
+		Valid:       valid,
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", prefix, m.sprintf(MsgSeeHelp, m.describeError(err), fullcmd))
+	printSuggestions(os.Stderr, err, m)
+	return nil, err
+}
+
+// checkRequired ensures that the user has specified the required options of
+// the commands in the chain, which ends with this command. The user may specify
+// a persistent option either after the name of the command declaring it or
+// after the name of a descendant. We must call this method after parsing the
+// leaf command, since we're using the parsers of the whole chain.
+func (p *CommandParser) checkRequired(chain []*CommandParser) (err error) {
+	if _, okay := p.options.(*subcommandHelp); okay {
+		return nil // we will parse again the command line adding --help
+	}
+	for idx, current := range chain {
+		parser := current.parser
+		parser.set.VisitAll(func(o getopt.Option) {
+			name := o.LongName()
+			if err != nil || !parser.required[name] || o.Seen() {
+				return
+			}
+			command := parser.set.Program()
+			if parser.persistent[name] {
+				for _, descendant := range chain[idx+1:] {
+					if descendant.parser.seenInherited(name, idx) {
+						return
+					}
+				}
+				command = p.parser.set.Program() // where the user can still specify it
+			}
+			err = &MissingRequiredError{
+				Command: command,
+				Index:   -1,
+				Option:  "--" + name,
+				Value:   "",
+			}
+		})
+	}
+	return
+}
+
+// visibleSubcommandNames returns the names and the aliases of the direct
+// subcommands that are not hidden.
+func (p *CommandParser) visibleSubcommandNames() (out []string) {
+	for _, sc := range p.visibleSubcommands() {
+		out = append(out, sc.name)
+		out = append(out, sc.aliases...)
+	}
+	return
+}
+
+// suggestSubcommands returns the names of the visible subcommands whose name
+// or aliases are similar to the given name. When an alias is similar to the
+// given name, we suggest the alias, since the user is probably using it.
+func (p *CommandParser) suggestSubcommands(name string) []string {
+	return suggest(name, p.visibleSubcommandNames())
+}
+
+// visibleSubcommands returns the direct subcommands that are not hidden.
+func (p *CommandParser) visibleSubcommands() (out []*CommandParser) {
 	for _, sc := range p.subcommands {
-		if subcmd != sc.name {
-			continue // not the command we're looking for
+		if !sc.hidden {
+			out = append(out, sc)
+		}
+	}
+	return
+}
+
+// commandNames returns the names of the given commands.
+func commandNames(commands []*CommandParser) (out []string) {
+	for _, sc := range commands {
+		out = append(out, sc.name)
+	}
+	return
+}
+
+// matchSubcommand returns the direct subcommands matching the given name or
+// alias. If prefixes is true, we return either the subcommand exactly matching
+// the name or all the visible subcommands having a name or alias with such a
+// prefix, such that the user must spell out the name of hidden subcommands.
+func (p *CommandParser) matchSubcommand(name string, prefixes bool) []*CommandParser {
+	byName := make(map[string]*CommandParser)
+	for _, sc := range p.subcommands {
+		for _, alias := range sc.aliases {
+			byName[alias] = sc
+		}
+	}
+	for _, sc := range p.subcommands {
+		byName[sc.name] = sc // names take precedence over aliases
+	}
+	if sc, found := byName[name]; found {
+		return []*CommandParser{sc}
+	}
+	if !prefixes {
+		return nil
+	}
+	var out []*CommandParser
+	seen := make(map[*CommandParser]bool)
+	for _, candidate := range matchPrefix(name, p.visibleSubcommandNames()) {
+		if sc := byName[candidate]; !seen[sc] {
+			seen[sc] = true
+			out = append(out, sc)
 		}
-		subchain := append([]*CommandParser{}, chain...)
-		subchain = append(subchain, sc)
-		return sc.getoptall(subchain, parser.Args())
 	}
+	return out
+}
+
+// resetParseState resets the state that parsing sets (e.g., whether the user
+// asked for help), such that we can parse several command lines.
+func (p *CommandParser) resetParseState() {
+	p.help, p.helpAll, p.helpFormat = false, false, ""
+	p.parser = nil
+	p.timeout = 0
+}
 
-	// 7. okay we have not found a subcommand, tell the user about this.
-	fmt.Fprintf(os.Stderr,
-		"%s: no such subcommand: '%s'. See '%s --help'.\n", cmd, subcmd, fullcmd)
-	return nil, ErrNoSuchSubcommand
+// optionsDefaults returns the string representation of the values of the
+// given options before parsing, which we show as their default values, since
+// parsing modifies the options and we may parse the command line many times.
+func optionsDefaults(options interface{}) map[string]string {
+	parser, err := newParserWrapper(options)
+	if err != nil {
+		return nil // we report this error when parsing
+	}
+	return parser.defaults
 }
 
 // newParserWrapper creates a new parser wrapper. This function also ensures
@@ -361,52 +695,88 @@ func (p *CommandParser) getoptall(chain []*CommandParser, args []string) (*Selec
 // nil error. On failure, instead, we return nil, the full command, and an error.
 func (p *CommandParser) newParserWrapper(chain []*CommandParser) (*parserWrapper, string, error) {
 	fullcmd := p.fullcmd(chain)
-	var config []Config
+	config := append([]Config{}, chain[0].configs...)
 	config = append(config, SetPositionalArgumentsPlaceholder(p.positionalArgumentsPlaceholder()))
 	config = append(config, SetProgramName(fullcmd))
+	if chain[0].prefixes {
+		config = append(config, AllowPrefixMatching())
+	}
 	parser, err := newParserWrapper(p.options, config...)
 	if err != nil {
 		return nil, fullcmd, err
 	}
-	parser.maybeAddHelpFlags(&p.help)
+	parser.setDefaults(p.defaults)
+	parser.maybeAddHelpFlags(&p.help, &p.helpFormat)
+	if p.hasHiddenSubcommands() {
+		parser.maybeAddHelpAllFlag(&p.helpAll)
+	}
+	p.maybeAddTimeoutOption(parser, chain[0])
+	if err := p.addPersistentOptions(parser, chain); err != nil {
+		return nil, fullcmd, err
+	}
+	// We cannot permute the arguments of a command with subcommands, since
+	// we need to stop processing options at the subcommand name.
+	parser.permute = (parser.permute || p.permute) && len(p.subcommands) <= 0
+	// We check the required options after handling -h/--help, such that
+	// the user can always obtain help, and at the leaf, since the user may
+	// specify persistent options after the subcommand name.
+	parser.deferRequired = true
 	return parser, fullcmd, nil
 }
 
-// newSelectedCommand creates a new instance of SelectedCommand from this CommandParser
-// and the current set of positional arguments for the subcommand.
-func (p *CommandParser) newSelectedCommand(args []string) *SelectedCommand {
+// newSelectedCommand creates a new instance of SelectedCommand from this CommandParser,
+// the chain of commands leading to it, and the current set of positional arguments.
+func (p *CommandParser) newSelectedCommand(chain []*CommandParser, args []string) *SelectedCommand {
 	return &SelectedCommand{
 		options: p.options,
 		args:    args,
+		chain:   chain,
 	}
 }
 
 // printHelp prints the help message.
 func (p *CommandParser) printHelp(
 	parser *parserWrapper, w io.Writer, chain []*CommandParser) {
-	p.printBriefUsage(w, chain)
-	p.printSubcommandDescription(w)
-	p.printOptions(w, chain)
-	p.printSubcommands(w, nil)
-}
-
-// printBriefUsage prints brief usage for this command parser.
-func (p *CommandParser) printBriefUsage(w io.Writer, chain []*CommandParser) {
-	var sb strings.Builder
-	sb.WriteString("\nUsage:")
-	for idx, entry := range chain {
-		sb.WriteString(" ")
-		sb.WriteString(entry.name)
-		if entry.hasOptions() {
-			sb.WriteString(" [options]")
-		}
-		if idx >= len(chain)-1 {
-			break
+	parser.helpRenderer().RenderHelp(w, p.newHelpModel(chain))
+}
+
+// newHelpModel creates the HelpModel describing this command.
+func (p *CommandParser) newHelpModel(chain []*CommandParser) *HelpModel {
+	m := chain[0].messages()
+	model := &HelpModel{
+		IsCommand:   true,
+		Chain:       nil,
+		Description: m.resolve(p.description),
+		Positional:  strings.TrimSpace(p.positionalArgumentsPlaceholder()),
+		Subcommands: nil,
+	}
+	all := p.helpAll || chain[0].showHiddenFromEnvironment()
+	if chain[0].directSubcommandsOnly() {
+		model.Subcommands = p.directHelpSubcommands(all)
+		model.DirectSubcommandsOnly = true
+	} else {
+		model.Subcommands = p.helpSubcommands(nil, "", all)
+	}
+	for idx := range model.Subcommands {
+		model.Subcommands[idx].Description = m.resolve(model.Subcommands[idx].Description)
+	}
+	model.LongDescription = m.resolve(p.longDescription)
+	for _, example := range p.examples {
+		example.Explanation = m.resolve(example.Explanation)
+		model.Examples = append(model.Examples, example)
+	}
+	model.Epilog = m.resolve(p.epilog)
+	for _, entry := range chain {
+		hc := HelpCommand{Name: entry.name}
+		parser, err := newParserWrapper(entry.options, chain[0].configs...)
+		if err == nil { // TODO(bassosimone): should we log this error?!
+			parser.setDefaults(entry.defaults)
+			entry.maybeAddTimeoutOption(parser, chain[0])
+			hc.Options = parser.helpOptions()
 		}
+		model.Chain = append(model.Chain, hc)
 	}
-	sb.WriteString(p.positionalArgumentsPlaceholder())
-	sb.WriteString("\n")
-	fmt.Fprint(w, sb.String())
+	return model
 }
 
 func (p *CommandParser) positionalArgumentsPlaceholder() string {
@@ -422,69 +792,79 @@ func (p *CommandParser) positionalArgumentsPlaceholder() string {
 	}
 }
 
-func (p *CommandParser) hasOptions() bool {
-	// TODO(bassosimone): should we warn here in case of error?
-	parser, err := newParserWrapper(p.options)
-	return err == nil && parser.numOptions() > 0
+// helpSubcommands returns the leaf subcommands recursively. When all is
+// true, we also include the hidden subcommands, except for the internal
+// `__complete` subcommand, which is not meant to be used directly. The
+// group argument is the group inherited from the ancestors, which we use
+// for the subcommands that do not have a group.
+func (p *CommandParser) helpSubcommands(names []string, group string, all bool) (out []HelpSubcommand) {
+	for _, sc := range p.listableSubcommands(all) {
+		newnames := append([]string{}, names...)
+		newnames = append(newnames, sc.name)
+		scgroup := sc.group
+		if scgroup == "" {
+			scgroup = group
+		}
+		if len(sc.subcommands) > 0 {
+			out = append(out, sc.helpSubcommands(newnames, scgroup, all)...)
+			continue
+		}
+		out = append(out, HelpSubcommand{
+			Names:       newnames,
+			Aliases:     sc.aliases,
+			Description: sc.description,
+			Group:       scgroup,
+		})
+	}
+	return
 }
 
-// printSubcommandDescription prints the command's description.
-func (p *CommandParser) printSubcommandDescription(w io.Writer) {
-	fmt.Fprintf(w, "\n")
-	doc := p.description
-	if !strings.HasSuffix(doc, ".") {
-		doc += "."
-	}
-	for _, line := range strings.Split(wordwrap.WrapString(doc, 72), "\n") {
-		fmt.Fprintf(w, "%s\n", line)
+// directHelpSubcommands is like helpSubcommands but only returns
+// the direct subcommands rather than the leaf subcommands.
+func (p *CommandParser) directHelpSubcommands(all bool) (out []HelpSubcommand) {
+	for _, sc := range p.listableSubcommands(all) {
+		out = append(out, HelpSubcommand{
+			Names:       []string{sc.name},
+			Aliases:     sc.aliases,
+			Description: sc.description,
+			Group:       sc.group,
+		})
 	}
-	fmt.Fprintf(w, "\n")
+	return
 }
 
-// printOptions prints the options up to this point in the chain.
-func (p *CommandParser) printOptions(w io.Writer, chain []*CommandParser) {
-	for _, entry := range chain {
-		parser, err := newParserWrapper(entry.options)
-		if err != nil {
-			// TODO(bassosimone): should we log this error?!
+// listableSubcommands returns the direct subcommands that we should list in
+// the help message. When all is true, we also include the hidden subcommands,
+// except for the internal `__complete` subcommand.
+func (p *CommandParser) listableSubcommands(all bool) (out []*CommandParser) {
+	for _, sc := range p.subcommands {
+		if _, okay := sc.options.(*subcommandComplete); okay {
 			continue
 		}
-		if parser.numOptions() <= 0 {
+		if sc.hidden && !all {
 			continue
 		}
-		fmt.Fprintf(w, "Options for %s:\n\n", entry.name)
-		parser.printOptions(w)
+		out = append(out, sc)
 	}
+	return
 }
 
-// printSubcommands prints the subcommands recursively.
-func (p *CommandParser) printSubcommands(w io.Writer, names []string) {
-	if len(p.subcommands) > 0 {
-		if len(names) <= 0 { // we need to print this only at the beginning
-			fmt.Fprintf(w, "Subcommands:\n\n")
-		}
-		for _, sc := range p.subcommands {
-			newnames := append([]string{}, names...)
-			newnames = append(newnames, sc.name)
-			if len(sc.subcommands) > 0 {
-				sc.printSubcommands(w, newnames)
-				continue
-			}
-			p.printSingleSubcommand(w, sc.description, newnames)
-		}
-	}
+// messages returns the messages for the catalog configured using
+// Configure. You should call this method on the toplevel command.
+func (p *CommandParser) messages() *messages {
+	return messagesFromConfigs(p.configs)
 }
 
-// printSingleCommand is an utility function for printing help for a single command
-func (p *CommandParser) printSingleSubcommand(w io.Writer, doc string, names []string) {
-	fmt.Fprintf(w, "  %s\n", strings.Join(names, " "))
-	if !strings.HasSuffix(doc, ".") {
-		doc += "."
-	}
-	for _, line := range strings.Split(wordwrap.WrapString(doc, 64), "\n") {
-		fmt.Fprintf(w, "             %s\n", line)
+// hasConfig returns whether the configs of this command contain a
+// config for which match returns true. You should call this method
+// on the toplevel command, since we use the toplevel configuration.
+func (p *CommandParser) hasConfig(match func(config Config) bool) bool {
+	for _, config := range p.configs {
+		if match(config) {
+			return true
+		}
 	}
-	fmt.Fprintf(w, "\n")
+	return false
 }
 
 // fullcmd returns the full command up to this point.
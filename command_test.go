@@ -0,0 +1,344 @@
+package getoptx
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// withProgramName sets os.Args[0], which Command uses as the name of the
+// toplevel command, for the duration of the current test.
+func withProgramName(t *testing.T, name string) {
+	saved := os.Args
+	os.Args = append([]string{name}, os.Args[1:]...)
+	t.Cleanup(func() {
+		os.Args = saved
+	})
+}
+
+// captureStderr redirects os.Stderr to a temporary file for the duration of
+// the current test and returns a function returning what we've written.
+func captureStderr(t *testing.T) func() string {
+	return captureFile(t, &os.Stderr)
+}
+
+// captureStdout is like captureStderr but redirects os.Stdout.
+func captureStdout(t *testing.T) func() string {
+	return captureFile(t, &os.Stdout)
+}
+
+// captureFile implements captureStderr and captureStdout.
+func captureFile(t *testing.T, target **os.File) func() string {
+	fp, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *target
+	*target = fp
+	t.Cleanup(func() {
+		*target = saved
+		fp.Close()
+	})
+	return func() string {
+		data, err := os.ReadFile(fp.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+// testMeasurementID is an option value implementing Completer, which we
+// use for testing the completion of option values.
+type testMeasurementID int64
+
+var (
+	_ getopt.Value = new(testMeasurementID)
+	_ Completer    = new(testMeasurementID)
+)
+
+// Set implements getopt.Value.Set.
+func (id *testMeasurementID) Set(value string, opt getopt.Option) error {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	*id = testMeasurementID(v)
+	return nil
+}
+
+// String implements getopt.Value.String.
+func (id *testMeasurementID) String() string {
+	return strconv.FormatInt(int64(*id), 10)
+}
+
+// Complete implements Completer.Complete.
+func (id *testMeasurementID) Complete(prefix string) (out []string) {
+	for _, candidate := range []string{"11", "12", "21"} {
+		if strings.HasPrefix(candidate, prefix) {
+			out = append(out, candidate)
+		}
+	}
+	return
+}
+
+// testOptions contains the options of the commands tree we use for testing.
+// We use `metavar:"value"` because, before we introduced metavars, the
+// placeholder was always "value", and the help golden files expect it.
+type testOptions struct {
+	Global struct {
+		Batch   bool   `doc:"emit JSON messages" short:"b"`
+		Logfile string `doc:"file where to write logs" short:"L" metavar:"value" persistent:"true"`
+		Verbose bool   `doc:"run in verbose mode, which prints a lot of debug messages that you typically do not want to see unless you are debugging" short:"v" persistent:"true"`
+	}
+	Run struct {
+		Input []string `doc:"add URL to measure" short:"i" metavar:"value" persistent:"true"`
+	}
+	Websites struct {
+		ForceHTTP3 bool `doc:"forces using HTTP3" short:"3"`
+	}
+	List struct {
+		ID testMeasurementID `doc:"ID of the result to show" metavar:"value"`
+	}
+}
+
+// newTestCLI creates the commands tree we use for testing
+// and configures it using the given config.
+func newTestCLI(options *testOptions, config ...Config) *CommandParser {
+	cli := Command(
+		"Network measurement tool",
+		&options.Global,
+		Subcommand(
+			"run", "Runs network measurements", &options.Run,
+			LeafSubcommand("websites", "Tests websites for censorship", &options.Websites,
+				NoPositionalArguments()),
+		),
+		LeafSubcommand("list", "Lists network measurements", &options.List),
+	)
+	cli.Configure(config...)
+	return cli
+}
+
+// testSubcommand returns the direct subcommand of cli with the given name.
+func testSubcommand(cli *CommandParser, name string) *CommandParser {
+	return cli.matchSubcommand(name, false)[0]
+}
+
+// newDocumentedTestCLI is like newTestCLI but the `list` command has
+// a long description, usage examples, and an epilog.
+func newDocumentedTestCLI(options *testOptions) *CommandParser {
+	cli := newTestCLI(options)
+	testSubcommand(cli, "list").
+		SetLongDescription("Lists the measurements that we have stored.\n\n"+
+			"Use --id to show a single measurement.").
+		AddExample("prog list", "Lists all the measurements").
+		AddExample("prog list --id 7", "Shows the measurement with ID 7").
+		SetEpilog("Report bugs to <https://example.com/issues>.")
+	return cli
+}
+
+func TestLongDescriptionExamplesAndEpilog(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+
+	t.Run("help", func(t *testing.T) {
+		var options testOptions
+		cli := newDocumentedTestCLI(&options)
+		stdout := captureStdout(t)
+		if _, err := cli.Getopt([]string{"prog", "list", "--help"}); err != nil {
+			t.Fatal(err)
+		}
+		expect := `
+Options for list:
+
+      --id value
+             ID of the result to show.
+
+Description:
+
+  Lists the measurements that we have stored.
+
+  Use --id to show a single measurement.
+
+Examples:
+
+  prog list
+             Lists all the measurements.
+
+  prog list --id 7
+             Shows the measurement with ID 7.
+
+Report bugs to <https://example.com/issues>.
+
+`
+		if output := stdout(); !strings.HasSuffix(output, expect) {
+			t.Fatalf("expected output ending with:\n%s\ngot:\n%s", expect, output)
+		}
+	})
+
+	t.Run("man", func(t *testing.T) {
+		var options testOptions
+		cli := newDocumentedTestCLI(&options)
+		dir := t.TempDir()
+		if err := cli.WriteManPages(dir); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "prog-list.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expect := range []string{
+			".SH DESCRIPTION\nLists network measurements.\n" +
+				".PP\nLists the measurements that we have stored.\n" +
+				".PP\nUse \\-\\-id to show a single measurement.\n",
+			".SH EXAMPLES\n.TP\n.B prog list\nLists all the measurements\n" +
+				".TP\n.B prog list \\-\\-id 7\nShows the measurement with ID 7\n",
+			".SH NOTES\nReport bugs to <https://example.com/issues>.\n",
+		} {
+			if !strings.Contains(string(data), expect) {
+				t.Fatalf("cannot find %q inside:\n%s", expect, string(data))
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var options testOptions
+		cli := newDocumentedTestCLI(&options)
+		var sb bytes.Buffer
+		if err := cli.WriteJSON(&sb); err != nil {
+			t.Fatal(err)
+		}
+		cd := decodeCommandDescription(t, sb.Bytes())
+		list := cd.Subcommands[0]
+		if list.LongDescription != "Lists the measurements that we have stored.\n\nUse --id to show a single measurement." {
+			t.Fatalf("unexpected long description: %q", list.LongDescription)
+		}
+		expect := []HelpExample{
+			{Command: "prog list", Explanation: "Lists all the measurements"},
+			{Command: "prog list --id 7", Explanation: "Shows the measurement with ID 7"},
+		}
+		if !reflect.DeepEqual(list.Examples, expect) {
+			t.Fatalf("expected %+v, got %+v", expect, list.Examples)
+		}
+		if list.Epilog != "Report bugs to <https://example.com/issues>." {
+			t.Fatalf("unexpected epilog: %q", list.Epilog)
+		}
+		if cd.LongDescription != "" || cd.Examples != nil || cd.Epilog != "" {
+			t.Fatalf("unexpected documentation for the toplevel command: %+v", cd)
+		}
+	})
+}
+
+func TestAliases(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+
+	// newCLI is like newTestCLI but `list` has aliases.
+	newCLI := func(options *testOptions) *CommandParser {
+		cli := newTestCLI(options)
+		testSubcommand(cli, "list").SetAliases("ls", "show")
+		return cli
+	}
+
+	for _, name := range []string{"list", "ls", "show"} {
+		t.Run("dispatch using "+name, func(t *testing.T) {
+			var options testOptions
+			sc, err := newCLI(&options).Getopt([]string{"prog", name, "--id", "7", "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.Options() != &options.List || options.List.ID != 7 {
+				t.Fatalf("unexpected selected command: %+v", sc)
+			}
+			if !reflect.DeepEqual(sc.Args(), []string{"a"}) {
+				t.Fatalf("unexpected args: %v", sc.Args())
+			}
+		})
+	}
+
+	t.Run("suggestions include aliases", func(t *testing.T) {
+		captureStderr(t)
+		var options testOptions
+		_, err := newCLI(&options).Getopt([]string{"prog", "shwo"})
+		var unknown *UnknownSubcommandError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected *UnknownSubcommandError, got %T", err)
+		}
+		if !reflect.DeepEqual(unknown.Suggestions, []string{"show"}) {
+			t.Fatalf("unexpected suggestions: %v", unknown.Suggestions)
+		}
+	})
+
+	t.Run("help lists aliases", func(t *testing.T) {
+		var options testOptions
+		cli := newCLI(&options)
+		stdout := captureStdout(t)
+		if _, err := cli.Getopt([]string{"prog", "--help"}); err != nil {
+			t.Fatal(err)
+		}
+		expect := "\n  list (ls, show)\n             Lists network measurements.\n"
+		if output := stdout(); !strings.Contains(output, expect) {
+			t.Fatalf("cannot find %q inside:\n%s", expect, output)
+		}
+	})
+
+	t.Run("help subcommand using an alias", func(t *testing.T) {
+		var options testOptions
+		cli := newCLI(&options)
+		stdout := captureStdout(t)
+		if _, err := cli.Getopt([]string{"prog", "help", "ls"}); err != nil {
+			t.Fatal(err)
+		}
+		expect := "\nUsage: prog [options] list [options] <argument> [<argument> ...]\n"
+		if output := stdout(); !strings.HasPrefix(output, expect) {
+			t.Fatalf("expected output starting with %q, got:\n%s", expect, output)
+		}
+	})
+
+	t.Run("an alias named help replaces the help subcommand", func(t *testing.T) {
+		var options testOptions
+		cli := Command(
+			"Network measurement tool",
+			&options.Global,
+			LeafSubcommand("assist", "Prints help", &options.List).SetAliases("help"),
+		)
+		if got := commandNames(cli.subcommands); !reflect.DeepEqual(got, []string{"__complete", "assist"}) {
+			t.Fatalf("unexpected subcommands: %v", got)
+		}
+		sc, err := cli.Getopt([]string{"prog", "help"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc.Options() != &options.List {
+			t.Fatalf("unexpected selected command: %+v", sc)
+		}
+	})
+
+	t.Run("dynamic completion follows aliases", func(t *testing.T) {
+		var options testOptions
+		expect := []string{"--help", "--id", "--logfile", "--verbose", "-L", "-h", "-v"}
+		if got := newCLI(&options).complete([]string{"show", "-"}); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("expected %v, got %v", expect, got)
+		}
+	})
+
+	t.Run("bash completion follows aliases", func(t *testing.T) {
+		var options testOptions
+		var sb bytes.Buffer
+		if err := newCLI(&options).WriteBashCompletion(&sb); err != nil {
+			t.Fatal(err)
+		}
+		got := runBashCompletion(t, sb.String(), "_prog_complete", "prog", "ls", "--i")
+		expect := []string{"--id"}
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("expected %v, got %v", expect, got)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package getoptx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// completionNode describes a command for generating completion scripts.
+type completionNode struct {
+	// names contains the names of the commands from the toplevel
+	// command to this command. The first name is the program name.
+	names []string
+
+	// description is the command description.
+	description string
+
+	// aliases contains the alternative names of this command.
+	aliases []string
+
+	// options contains the options of this command.
+	options []HelpOption
+
+	// inherited contains the persistent options of the ancestors.
+	inherited []HelpOption
+
+	// completers contains the long names of the options implementing
+	// Completer, whose values we complete using `prog __complete`. It
+	// is empty when we're describing a parser created using NewParser,
+	// which does not implement the "__complete" subcommand.
+	completers map[string]bool
+
+	// positional indicates whether the command accepts
+	// positional arguments other than subcommands.
+	positional bool
+
+	// children contains the subcommands.
+	children []*completionNode
+}
+
+// newCompletionTree creates the tree of completionNode describing the
+// commands reachable from this command, which should be the toplevel
+// command, except for the internal `help` subcommand.
+func (p *CommandParser) newCompletionTree() (*completionNode, error) {
+	nodes := make(map[string]*completionNode)
+	var (
+		root *completionNode
+		err  error
+	)
+	m := p.messages()
+	dynamic := p.isInternalComplete("__complete") // registered by Command
+	p.walkCommands([]*CommandParser{p}, func(chain []*CommandParser) {
+		if err != nil {
+			return
+		}
+		current := chain[len(chain)-1]
+		var parser *parserWrapper
+		parser, _, err = current.newParserWrapper(chain)
+		if err != nil {
+			return
+		}
+		node := &completionNode{
+			names:       strings.Split(docCommandName(chain), " "),
+			description: m.resolve(current.description),
+			aliases:     current.aliases,
+			options:     parser.helpOptions(),
+			completers:  make(map[string]bool),
+			positional:  len(current.subcommands) <= 0 && current.pac.maxArgs > 0,
+		}
+		for name := range parser.completers {
+			node.completers[name] = dynamic
+		}
+		nodes[docCommandName(chain)] = node
+		if len(chain) < 2 {
+			root = node
+			return
+		}
+		parent := nodes[docCommandName(chain[:len(chain)-1])]
+		node.inherited = parser.inheritedOptions()
+		parent.children = append(parent.children, node)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// newParserCompletionTree creates the single completionNode describing the
+// given parser, which must have been created using NewParser.
+func newParserCompletionTree(parser Parser) (*completionNode, error) {
+	pw, okay := parser.(*parserWrapper)
+	if !okay {
+		return nil, errors.New("getoptx: parser not created using NewParser")
+	}
+	name := pw.set.Program()
+	if name == "" {
+		name = os.Args[0]
+	}
+	return &completionNode{
+		names:      []string{filepath.Base(name)},
+		options:    pw.helpOptions(),
+		positional: pw.pac.maxArgs > 0,
+	}, nil
+}
+
+// flatten returns this node and all its descendants in depth-first order.
+func (n *completionNode) flatten() []*completionNode {
+	out := []*completionNode{n}
+	for _, child := range n.children {
+		out = append(out, child.flatten()...)
+	}
+	return out
+}
+
+// path returns the command path separated by spaces.
+func (n *completionNode) path() string {
+	return strings.Join(n.names, " ")
+}
+
+// aliasPaths returns the path of this command followed by the paths
+// obtained by replacing the command name with each of its aliases.
+func (n *completionNode) aliasPaths() []string {
+	out := []string{n.path()}
+	for _, alias := range n.aliases {
+		names := append(append([]string{}, n.names[:len(n.names)-1]...), alias)
+		out = append(out, strings.Join(names, " "))
+	}
+	return out
+}
+
+// name returns the name of this command.
+func (n *completionNode) name() string {
+	return n.names[len(n.names)-1]
+}
+
+// allOptions returns the options of this command and the
+// persistent options inherited from its ancestors.
+func (n *completionNode) allOptions() []HelpOption {
+	return append(append([]HelpOption{}, n.inherited...), n.options...)
+}
+
+// completerOptionWords returns the words (e.g., "-I", "--id") of the
+// options valid for this command whose values we complete dynamically.
+func (n *completionNode) completerOptionWords() []string {
+	var filtered []HelpOption
+	for _, o := range n.allOptions() {
+		if n.completers[o.Long] {
+			filtered = append(filtered, o)
+		}
+	}
+	return optionWords(filtered)
+}
+
+// uniqueOptions returns the given options without duplicate long names,
+// keeping the last occurrence, which belongs to the innermost command.
+func uniqueOptions(options []HelpOption) (out []HelpOption) {
+	seen := make(map[string]bool)
+	for idx := len(options) - 1; idx >= 0; idx-- {
+		if o := options[idx]; !seen[o.Long] {
+			seen[o.Long] = true
+			out = append([]HelpOption{o}, out...)
+		}
+	}
+	return
+}
+
+// optionWords returns the words (e.g., "-v", "--verbose") for the given
+// options. The returned list does not contain duplicate words.
+func optionWords(options []HelpOption) (out []string) {
+	seen := make(map[string]bool)
+	for _, o := range options {
+		for _, word := range []string{"--" + o.Long, "-" + o.Short} {
+			if word != "--" && word != "-" && !seen[word] {
+				seen[word] = true
+				out = append(out, word)
+			}
+		}
+	}
+	return
+}
+
+// valueOptionWords is like optionWords but only returns the words
+// corresponding to options that take a value.
+func valueOptionWords(options []HelpOption) []string {
+	var filtered []HelpOption
+	for _, o := range options {
+		if !o.IsFlag {
+			filtered = append(filtered, o)
+		}
+	}
+	return optionWords(filtered)
+}
+
+// shellQuote quotes a string for the POSIX shell using single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellIdentifier converts a string into a valid shell identifier.
+func shellIdentifier(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package getoptx
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// Completer is implemented by option values that know how to complete their
+// own values at runtime (e.g., by reading IDs from a local database). For
+// example:
+//
+//     type MeasurementID int64
+//
+//     func (id *MeasurementID) Complete(prefix string) []string {
+//       return loadMeasurementIDsStartingWith(prefix)
+//     }
+//
+//     type ListOptions struct {
+//       ID MeasurementID `doc:"ID of the result to show" short:"I"`
+//     }
+//
+// Note that the option value must also implement getopt.Value, since the
+// underlying parser must know how to set it. The bash, zsh, and fish scripts
+// generated for a commands tree created using Command complete the values of
+// such options by running the hidden `prog __complete` subcommand.
+type Completer interface {
+	// Complete returns the possible values starting with prefix.
+	Complete(prefix string) []string
+}
+
+// isInternalComplete returns whether name is the name of the internal
+// "__complete" subcommand registered by Command.
+func (p *CommandParser) isInternalComplete(name string) bool {
+	for _, sc := range p.subcommands {
+		if _, okay := sc.options.(*subcommandComplete); okay && sc.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutInternalComplete returns the given commands except for the internal
+// "__complete" subcommand, which is only valid as the first argument, where
+// Getopt intercepts it before parsing any option.
+func withoutInternalComplete(commands []*CommandParser) (out []*CommandParser) {
+	for _, sc := range commands {
+		if _, okay := sc.options.(*subcommandComplete); !okay {
+			out = append(out, sc)
+		}
+	}
+	return
+}
+
+// complete implements the "__complete" subcommand. The words argument contains
+// the command line without the program name, where the last word is the one
+// we're completing (e.g., `run --inp`, or `run ""` to complete a new word).
+// We return the candidate completions sorted alphabetically.
+//
+// This protocol allows any shell to implement dynamic completion by running
+// `prog __complete <words...>` and reading one candidate per line.
+func (p *CommandParser) complete(words []string) []string {
+	if len(words) < 1 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	// 1. walk the words before the current one to figure out which is
+	// the current command, skipping the values of options.
+	chain := []*CommandParser{p}
+	parser, _, err := p.newParserWrapper(chain)
+	if err != nil {
+		return nil
+	}
+	var (
+		dashdash bool
+		owner    string
+	)
+	for _, word := range words[:len(words)-1] {
+		if owner != "" {
+			owner = ""
+			continue
+		}
+		if !dashdash && word == "--" {
+			dashdash = true
+			continue
+		}
+		if !dashdash && strings.HasPrefix(word, "-") && word != "-" {
+			owner = parser.nextArgumentOwner(word)
+			continue
+		}
+		last := chain[len(chain)-1]
+		if matches := withoutInternalComplete(last.matchSubcommand(word, p.prefixes)); len(matches) == 1 {
+			chain = append(chain, matches[0])
+			if parser, _, err = matches[0].newParserWrapper(chain); err != nil {
+				return nil
+			}
+			dashdash = false
+		}
+	}
+
+	// 2. complete the value of an option, an option, or a subcommand.
+	var candidates []string
+	switch {
+	case owner != "":
+		candidates = parser.completeOptionValue(owner, current)
+	case !dashdash && strings.HasPrefix(current, "--") && strings.Contains(current, "="):
+		eq := strings.Index(current, "=")
+		for _, value := range parser.completeOptionValue(current[:eq], current[eq+1:]) {
+			candidates = append(candidates, current[:eq+1]+value)
+		}
+	case !dashdash && strings.HasPrefix(current, "-"):
+		// The current command accepts its own options and the persistent
+		// options of its ancestors, which newParserWrapper registered.
+		candidates = optionWords(append(parser.helpOptions(), parser.inheritedOptions()...))
+	default:
+		candidates = commandNames(chain[len(chain)-1].visibleSubcommands())
+	}
+	var out []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			out = append(out, candidate)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// completeOptionValue uses the Completer of the given option (e.g., "--id"
+// or "-I"), if any, to complete its value. The option must be valid for the
+// current command, either because the command declares it or because it is
+// a persistent option of an ancestor command.
+func (p *parserWrapper) completeOptionValue(option, prefix string) []string {
+	if name := p.longName(option); name != "" {
+		if completer, found := p.completers[name]; found {
+			return completer.Complete(prefix)
+		}
+	}
+	return nil
+}
+
+// longName returns the long name of the given option (e.g., "--id"
+// or "-I") or an empty string if the option does not exist.
+func (p *parserWrapper) longName(option string) (name string) {
+	p.set.VisitAll(func(o getopt.Option) {
+		if option == "--"+o.LongName() || (o.ShortName() != "" && option == "-"+o.ShortName()) {
+			name = o.LongName()
+		}
+	})
+	return
+}
@@ -0,0 +1,165 @@
+package getoptx
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	type testcase struct {
+		name   string
+		words  []string
+		expect []string
+	}
+
+	testcases := []testcase{{
+		name:   "subcommands",
+		words:  []string{""},
+		expect: []string{"help", "list", "run"},
+	}, {
+		name:   "subcommands with prefix",
+		words:  []string{"--verbose", "r"},
+		expect: []string{"run"},
+	}, {
+		name:   "nested subcommands",
+		words:  []string{"run", "w"},
+		expect: []string{"websites"},
+	}, {
+		name:   "toplevel options",
+		words:  []string{"--"},
+		expect: []string{"--batch", "--help", "--logfile", "--verbose"},
+	}, {
+		name:   "subcommand options",
+		words:  []string{"run", "--"},
+		expect: []string{"--help", "--input", "--logfile", "--verbose"},
+	}, {
+		name:   "value using Completer",
+		words:  []string{"list", "--id", "1"},
+		expect: []string{"11", "12"},
+	}, {
+		name:   "value using Completer with equal sign",
+		words:  []string{"list", "--id=2"},
+		expect: []string{"--id=21"},
+	}, {
+		name:   "value of option declared by another command",
+		words:  []string{"run", "--id=1"},
+		expect: nil,
+	}, {
+		name:   "value without Completer",
+		words:  []string{"run", "-i", ""},
+		expect: nil,
+	}, {
+		name:   "skipping option values",
+		words:  []string{"--logfile", "run", ""},
+		expect: []string{"help", "list", "run"},
+	}, {
+		name:   "no subcommands after a leaf",
+		words:  []string{"list", "--", ""},
+		expect: nil,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options testOptions
+			got := newTestCLI(&options).complete(tc.words)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Fatalf("expected %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestCompleteIsOnlyValidAsFirstArgument(t *testing.T) {
+	withProgramName(t, "prog")
+	captureStderr(t)
+	var options testOptions
+	cli := newTestCLI(&options)
+	_, err := cli.Getopt([]string{"prog", "--verbose", "__complete", "list"})
+	var use *UnknownSubcommandError
+	if !errors.As(err, &use) {
+		t.Fatalf("expected *UnknownSubcommandError, got %T %v", err, err)
+	}
+	if use.Index != 2 || use.Name != "__complete" {
+		t.Fatalf("unexpected error: %+v", use)
+	}
+}
+
+func TestCompletionScriptsUseComplete(t *testing.T) {
+	withProgramName(t, "prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+
+	type testcase struct {
+		name    string
+		write   func(w io.Writer) error
+		expect  []string
+		without []string
+	}
+
+	testcases := []testcase{{
+		name:  "bash",
+		write: cli.WriteBashCompletion,
+		expect: []string{
+			"\t\t'prog list --id')\n",
+			"__complete \"${COMP_WORDS[@]:1:COMP_CWORD}\"",
+		},
+		without: []string{"'prog run -i')\n\t\t\tlocal IFS"},
+	}, {
+		name:  "zsh",
+		write: cli.WriteZshCompletion,
+		expect: []string{
+			"_prog__complete() {\n",
+			"'--id=[ID of the result to show]:value:_prog__complete'",
+			"{'-i+','--input='}'[add URL to measure]:value: '",
+		},
+	}, {
+		name:  "fish",
+		write: cli.WriteFishCompletion,
+		expect: []string{
+			"function __prog_complete_values\n",
+			"-l 'id' -r -f -a '(__prog_complete_values)'",
+		},
+		without: []string{"-l 'input' -r -f -a"},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := tc.write(&sb); err != nil {
+				t.Fatal(err)
+			}
+			script := sb.String()
+			for _, expect := range tc.expect {
+				if !strings.Contains(script, expect) {
+					t.Fatalf("cannot find %q inside:\n%s", expect, script)
+				}
+			}
+			for _, without := range tc.without {
+				if strings.Contains(script, without) {
+					t.Fatalf("unexpected %q inside:\n%s", without, script)
+				}
+			}
+		})
+	}
+
+	t.Run("parsers do not implement __complete", func(t *testing.T) {
+		parser, err := NewParser(&options.List)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, write := range []func(io.Writer, Parser) error{
+			WriteBashCompletion, WriteZshCompletion, WriteFishCompletion,
+		} {
+			var sb strings.Builder
+			if err := write(&sb, parser); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(sb.String(), "__complete") {
+				t.Fatalf("unexpected __complete inside:\n%s", sb.String())
+			}
+		}
+	})
+}
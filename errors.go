@@ -0,0 +1,189 @@
+package getoptx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// UnknownOptionError indicates that the command line contains an option
+// that the parser does not know about.
+type UnknownOptionError struct {
+	// Command is the full command path (e.g., "prog run websites").
+	Command string
+
+	// Index is the index of the offending argument inside argv.
+	Index int
+
+	// Option is the unknown option name including dashes (e.g., "--inptu").
+	Option string
+
+	// Value is the offending argument as it appears inside argv.
+	Value string
+
+	// Suggestions contains the known long options that are
+	// similar to Option, if any, sorted by similarity.
+	Suggestions []string
+}
+
+// Error implements error.
+func (e *UnknownOptionError) Error() string {
+	return fmt.Sprintf("unknown option: %s", e.Option)
+}
+
+// MissingRequiredError indicates that a required option was not provided.
+type MissingRequiredError struct {
+	// Command is the full command path (e.g., "prog run websites").
+	Command string
+
+	// Index is always -1 because the option does not appear inside argv.
+	Index int
+
+	// Option is the missing option name including dashes (e.g., "--input").
+	Option string
+
+	// Value is always empty because the option does not appear inside argv.
+	Value string
+}
+
+// Error implements error.
+func (e *MissingRequiredError) Error() string {
+	return fmt.Sprintf("option %s is mandatory", e.Option)
+}
+
+// InvalidValueError indicates that an option's value is missing, was
+// not expected, or cannot be converted to the option's type.
+type InvalidValueError struct {
+	// Command is the full command path (e.g., "prog run websites").
+	Command string
+
+	// Index is the index of the offending option inside argv.
+	Index int
+
+	// Option is the option name including dashes (e.g., "--id").
+	Option string
+
+	// Value is the offending value, which may be empty if missing.
+	Value string
+
+	// Err is the underlying error.
+	Err error
+
+	// code is pborman's error code, which we use for translating.
+	code getopt.ErrorCode
+}
+
+// Error implements error.
+func (e *InvalidValueError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap allows using errors.Is and errors.As with the underlying error.
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownSubcommandError indicates that we don't know a subcommand
+// with the given name. This error wraps ErrNoSuchSubcommand.
+type UnknownSubcommandError struct {
+	// Command is the full path of the parent command (e.g., "prog run").
+	Command string
+
+	// Index is the index of the offending subcommand name inside argv.
+	Index int
+
+	// Name is the unknown subcommand name.
+	Name string
+
+	// Suggestions contains the known subcommands that are
+	// similar to Name, if any, sorted by similarity.
+	Suggestions []string
+
+	// Valid contains the names of all the valid subcommands.
+	Valid []string
+}
+
+// Error implements error.
+func (e *UnknownSubcommandError) Error() string {
+	return fmt.Sprintf("%s: '%s'", ErrNoSuchSubcommand.Error(), e.Name)
+}
+
+// Unwrap allows using errors.Is(err, ErrNoSuchSubcommand).
+func (e *UnknownSubcommandError) Unwrap() error {
+	return ErrNoSuchSubcommand
+}
+
+// AmbiguousOptionError indicates that, when using AllowPrefixMatching, the
+// command line contains a prefix matching more than a single long option.
+type AmbiguousOptionError struct {
+	// Command is the full command path (e.g., "prog run websites").
+	Command string
+
+	// Index is the index of the offending argument inside argv.
+	Index int
+
+	// Option is the ambiguous option prefix including dashes (e.g., "--ver").
+	Option string
+
+	// Value is the offending argument as it appears inside argv.
+	Value string
+
+	// Candidates contains the long options matching the prefix.
+	Candidates []string
+}
+
+// Error implements error.
+func (e *AmbiguousOptionError) Error() string {
+	return fmt.Sprintf("ambiguous option: %s (could be %s)", e.Option, quoteAndJoin(e.Candidates))
+}
+
+// AmbiguousSubcommandError indicates that, when using prefix matching, the
+// command line contains a prefix matching more than a single subcommand.
+type AmbiguousSubcommandError struct {
+	// Command is the full path of the parent command (e.g., "prog run").
+	Command string
+
+	// Index is the index of the offending subcommand name inside argv.
+	Index int
+
+	// Name is the ambiguous subcommand prefix.
+	Name string
+
+	// Candidates contains the subcommands matching the prefix.
+	Candidates []string
+}
+
+// Error implements error.
+func (e *AmbiguousSubcommandError) Error() string {
+	return fmt.Sprintf("ambiguous subcommand: '%s' (could be %s)", e.Name, quoteAndJoin(e.Candidates))
+}
+
+// newParseError converts an error returned by pborman's parser into one
+// of our typed errors. The index argument is the index in argv of the
+// argument that caused the error and arg is such argument. We return
+// the original error when we do not know how to convert it.
+func newParseError(err error, command string, index int, arg string) error {
+	var gerr *getopt.Error
+	if !errors.As(err, &gerr) {
+		return err
+	}
+	switch gerr.ErrorCode {
+	case getopt.UnknownOption:
+		return &UnknownOptionError{
+			Command: command,
+			Index:   index,
+			Option:  gerr.Name,
+			Value:   arg,
+		}
+	default:
+		return &InvalidValueError{
+			Command: command,
+			Index:   index,
+			Option:  gerr.Name,
+			Value:   gerr.Parameter,
+			Err:     gerr.Err,
+			code:    gerr.ErrorCode,
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package getoptx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect error
+	}
+
+	testcases := []testcase{{
+		name: "unknown option at toplevel",
+		argv: []string{"prog", "-v", "--verbsoe", "list"},
+		expect: &UnknownOptionError{
+			Command:     "prog",
+			Index:       2,
+			Option:      "--verbsoe",
+			Value:       "--verbsoe",
+			Suggestions: []string{"--verbose"},
+		},
+	}, {
+		name: "unknown option in subcommand",
+		argv: []string{"prog", "-v", "run", "--input", "x", "--inptu", "y"},
+		expect: &UnknownOptionError{
+			Command:     "prog run",
+			Index:       5,
+			Option:      "--inptu",
+			Value:       "--inptu",
+			Suggestions: []string{"--input"},
+		},
+	}, {
+		name: "unknown short option",
+		argv: []string{"prog", "list", "-x"},
+		expect: &UnknownOptionError{
+			Command: "prog list",
+			Index:   2,
+			Option:  "-x",
+			Value:   "-x",
+		},
+	}, {
+		name: "invalid value",
+		argv: []string{"prog", "list", "--id", "zz"},
+		expect: &InvalidValueError{
+			Command: "prog list",
+			Index:   2,
+			Option:  "--id",
+			Value:   "zz",
+		},
+	}, {
+		name: "missing value",
+		argv: []string{"prog", "list", "--id"},
+		expect: &InvalidValueError{
+			Command: "prog list",
+			Index:   2,
+			Option:  "--id",
+		},
+	}, {
+		name: "unknown subcommand",
+		argv: []string{"prog", "-v", "lsit"},
+		expect: &UnknownSubcommandError{
+			Command:     "prog",
+			Index:       2,
+			Name:        "lsit",
+			Suggestions: []string{"list"},
+			Valid:       []string{"help", "list", "run"},
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			captureStderr(t)
+			var options testOptions
+			cli := newTestCLI(&options)
+			_, err := cli.Getopt(tc.argv)
+			if ive, okay := err.(*InvalidValueError); okay {
+				ive.Err, ive.code = nil, 0 // pborman's error is not part of our API
+			}
+			if !reflect.DeepEqual(err, tc.expect) {
+				t.Fatalf("expected %#v, got %#v", tc.expect, err)
+			}
+		})
+	}
+}
+
+func TestTypedErrorsUnwrap(t *testing.T) {
+	withProgramName(t, "prog")
+	captureStderr(t)
+	var options testOptions
+	cli := newTestCLI(&options)
+
+	_, err := cli.Getopt([]string{"prog", "nonexistent"})
+	if !errors.Is(err, ErrNoSuchSubcommand) {
+		t.Fatalf("expected ErrNoSuchSubcommand, got %v", err)
+	}
+	if err.Error() != "no such subcommand: 'nonexistent'" {
+		t.Fatalf("unexpected error message: %s", err.Error())
+	}
+
+	_, err = cli.Getopt([]string{"prog", "list", "--id", "zz"})
+	var ive *InvalidValueError
+	if !errors.As(err, &ive) {
+		t.Fatalf("expected *InvalidValueError, got %#v", err)
+	}
+	if errors.Unwrap(err) != ive.Err || err.Error() != ive.Err.Error() {
+		t.Fatalf("unexpected underlying error: %#v", ive.Err)
+	}
+}
+
+func TestMissingRequiredDoesNotPreventHelp(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type options struct {
+		Global struct {
+			Logfile string `doc:"file where to write logs" required:"true"`
+		}
+		Run struct {
+			Input string `doc:"add URL to measure" required:"true"`
+		}
+	}
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect *MissingRequiredError
+	}
+
+	testcases := []testcase{{
+		name: "toplevel --help",
+		argv: []string{"prog", "--help"},
+	}, {
+		name: "subcommand --help",
+		argv: []string{"prog", "run", "--help"},
+	}, {
+		name: "help subcommand",
+		argv: []string{"prog", "help", "run"},
+	}, {
+		name: "missing toplevel option",
+		argv: []string{"prog", "run", "--input", "x"},
+		expect: &MissingRequiredError{
+			Command: "prog",
+			Index:   -1,
+			Option:  "--logfile",
+		},
+	}, {
+		name: "missing subcommand option",
+		argv: []string{"prog", "--logfile", "x", "run"},
+		expect: &MissingRequiredError{
+			Command: "prog run",
+			Index:   -1,
+			Option:  "--input",
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			captureStdout(t)
+			captureStderr(t)
+			var opts options
+			cli := Command(
+				"Network measurement tool",
+				&opts.Global,
+				LeafSubcommand("run", "Runs measurements", &opts.Run),
+			)
+			selected, err := cli.Getopt(tc.argv)
+			if tc.expect != nil {
+				if !reflect.DeepEqual(err, tc.expect) {
+					t.Fatalf("expected %#v, got %#v", tc.expect, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, okay := selected.Options().(*HasPrintedHelp); !okay {
+				t.Fatalf("expected *HasPrintedHelp, got %T", selected.Options())
+			}
+		})
+	}
+}
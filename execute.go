@@ -0,0 +1,101 @@
+package getoptx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RunFunc is a function implementing a command. The sc argument is the
+// selected command, which allows you to access the positional arguments.
+type RunFunc func(ctx context.Context, sc *SelectedCommand) error
+
+// ErrNoRunFunc indicates that Execute selected a command for which
+// you did not configure any RunFunc using CommandParser.SetRun.
+var ErrNoRunFunc = errors.New("no run function for the selected command")
+
+// SetRun sets the function implementing this command, which Execute calls
+// when the user selects this command. Registering the function next to the
+// command definition removes the need for type-switching on the value
+// returned by SelectedCommand.Options. This method returns the command
+// itself, so that you can write, e.g.:
+//
+//     getoptx.LeafSubcommand("list", "Lists measurements", &options.List).
+//       SetRun(func(ctx context.Context, sc *getoptx.SelectedCommand) error {
+//         return list(ctx, &options.List)
+//       })
+func (p *CommandParser) SetRun(fn RunFunc) *CommandParser {
+	p.run = fn
+	return p
+}
+
+// SetPreRun sets a hook that Execute calls before calling the RunFunc
+// of the selected command when the user selects either this command or any
+// of its subcommands. Execute calls the PreRun hooks along the selected path
+// in order, starting from the toplevel command (e.g., first the toplevel
+// command, then `run`, and then `websites`), which is useful, e.g., to
+// configure logging according to global options such as `--verbose`. When
+// a hook fails, Execute returns its error without calling the subsequent
+// hooks and the RunFunc. This method returns the command itself.
+func (p *CommandParser) SetPreRun(fn RunFunc) *CommandParser {
+	p.preRun = fn
+	return p
+}
+
+// SetPostRun sets a hook that Execute calls after the RunFunc of the
+// selected command has successfully returned when the user selects either
+// this command or any of its subcommands. Like PreRun hooks, Execute calls
+// the PostRun hooks along the selected path in order, starting from the
+// toplevel command, and stops at the first failure. This method
+// returns the command itself.
+func (p *CommandParser) SetPostRun(fn RunFunc) *CommandParser {
+	p.postRun = fn
+	return p
+}
+
+// Execute parses the command line arguments like Getopt does and then calls
+// the RunFunc of the selected command, returning its result. When the user
+// requests help, Execute prints help and returns nil. When parsing fails,
+// Execute returns the error, which Getopt has already printed on the
+// standard error. When the selected command does not have a RunFunc,
+// Execute returns an error wrapping ErrNoRunFunc. See SetPreRun and
+// SetPostRun for running hooks around the RunFunc and CancelOnSignals and
+// AddTimeoutOption for configuring the context passed to the RunFunc.
+func (p *CommandParser) Execute(ctx context.Context, args []string) error {
+	sc, err := p.Getopt(args)
+	if err != nil {
+		return err
+	}
+	if _, okay := sc.options.(*HasPrintedHelp); okay {
+		return nil
+	}
+	command := sc.chain[len(sc.chain)-1]
+	if command.run == nil {
+		return fmt.Errorf("%w: %s", ErrNoRunFunc, command.fullcmd(sc.chain))
+	}
+	ctx, cancel := executionContext(ctx, sc.chain)
+	defer cancel()
+	// 1. run the PreRun hooks from the toplevel command
+	for _, entry := range sc.chain {
+		if entry.preRun == nil {
+			continue
+		}
+		if err := entry.preRun(ctx, sc); err != nil {
+			return err
+		}
+	}
+	// 2. run the selected command
+	if err := command.run(ctx, sc); err != nil {
+		return err
+	}
+	// 3. run the PostRun hooks from the toplevel command
+	for _, entry := range sc.chain {
+		if entry.postRun == nil {
+			continue
+		}
+		if err := entry.postRun(ctx, sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
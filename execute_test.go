@@ -0,0 +1,225 @@
+package getoptx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	withProgramName(t, "prog")
+
+	// newCLI is like newTestCLI but `list` has a RunFunc that records
+	// its arguments and returns err, while `run websites` has none.
+	type record struct {
+		called bool
+		ctx    context.Context
+		sc     *SelectedCommand
+	}
+	newCLI := func(options *testOptions, rec *record, err error) *CommandParser {
+		cli := newTestCLI(options)
+		testSubcommand(cli, "list").SetRun(func(ctx context.Context, sc *SelectedCommand) error {
+			rec.called, rec.ctx, rec.sc = true, ctx, sc
+			return err
+		})
+		return cli
+	}
+
+	t.Run("dispatch", func(t *testing.T) {
+		var (
+			options testOptions
+			rec     record
+		)
+		type contextKey struct{}
+		ctx := context.WithValue(context.Background(), contextKey{}, "value")
+		err := newCLI(&options, &rec, nil).Execute(ctx, []string{"prog", "-v", "list", "--id", "7", "a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rec.called {
+			t.Fatal("did not call the RunFunc")
+		}
+		if rec.ctx.Value(contextKey{}) != "value" {
+			t.Fatal("the RunFunc context does not derive from the Execute context")
+		}
+		if rec.sc.Options() != &options.List || !reflect.DeepEqual(rec.sc.Args(), []string{"a", "b"}) {
+			t.Fatalf("unexpected selected command: %+v", rec.sc)
+		}
+		if !options.Global.Verbose || options.List.ID != 7 {
+			t.Fatalf("unexpected options: %+v", options)
+		}
+	})
+
+	t.Run("run error", func(t *testing.T) {
+		var (
+			options testOptions
+			rec     record
+		)
+		expect := errors.New("mocked error")
+		err := newCLI(&options, &rec, expect).Execute(context.Background(), []string{"prog", "list"})
+		if err != expect {
+			t.Fatalf("expected %v, got %v", expect, err)
+		}
+	})
+
+	t.Run("no run function", func(t *testing.T) {
+		var (
+			options testOptions
+			rec     record
+		)
+		err := newCLI(&options, &rec, nil).Execute(context.Background(), []string{"prog", "run", "websites"})
+		if !errors.Is(err, ErrNoRunFunc) {
+			t.Fatalf("expected ErrNoRunFunc, got %v", err)
+		}
+		if expect := "no run function for the selected command: prog run websites"; err.Error() != expect {
+			t.Fatalf("expected %q, got %q", expect, err.Error())
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		captureStdout(t)
+		var (
+			options testOptions
+			rec     record
+		)
+		err := newCLI(&options, &rec, nil).Execute(context.Background(), []string{"prog", "list", "--help"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.called {
+			t.Fatal("unexpected call to the RunFunc")
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		captureStderr(t)
+		var (
+			options testOptions
+			rec     record
+		)
+		err := newCLI(&options, &rec, nil).Execute(context.Background(), []string{"prog", "list", "--nonexistent"})
+		var unknown *UnknownOptionError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected *UnknownOptionError, got %T", err)
+		}
+		if rec.called {
+			t.Fatal("unexpected call to the RunFunc")
+		}
+	})
+}
+
+func TestExecuteHooks(t *testing.T) {
+	withProgramName(t, "prog")
+
+	// newCLI is like newTestCLI but each command along `prog run websites`
+	// has hooks appending to calls, `list` only has a RunFunc, and the hook
+	// named failing fails.
+	newCLI := func(options *testOptions, calls *[]string, failing string) *CommandParser {
+		hook := func(name string) RunFunc {
+			return func(ctx context.Context, sc *SelectedCommand) error {
+				*calls = append(*calls, name)
+				if name == failing {
+					return errors.New("mocked error")
+				}
+				return nil
+			}
+		}
+		cli := newTestCLI(options).SetPreRun(hook("prog:pre")).SetPostRun(hook("prog:post"))
+		run := testSubcommand(cli, "run").SetPreRun(hook("run:pre")).SetPostRun(hook("run:post"))
+		testSubcommand(run, "websites").
+			SetPreRun(hook("websites:pre")).
+			SetRun(hook("websites:run")).
+			SetPostRun(hook("websites:post"))
+		testSubcommand(cli, "list").SetRun(hook("list:run"))
+		return cli
+	}
+
+	type testcase struct {
+		name    string
+		argv    []string
+		failing string
+		expect  []string
+	}
+
+	testcases := []testcase{{
+		name:    "order along the chain",
+		argv:    []string{"prog", "run", "websites"},
+		failing: "",
+		expect: []string{
+			"prog:pre", "run:pre", "websites:pre", "websites:run",
+			"prog:post", "run:post", "websites:post",
+		},
+	}, {
+		name:    "only the hooks of the selected path",
+		argv:    []string{"prog", "list"},
+		failing: "",
+		expect:  []string{"prog:pre", "list:run", "prog:post"},
+	}, {
+		name:    "failing PreRun hook",
+		argv:    []string{"prog", "run", "websites"},
+		failing: "run:pre",
+		expect:  []string{"prog:pre", "run:pre"},
+	}, {
+		name:    "failing RunFunc",
+		argv:    []string{"prog", "run", "websites"},
+		failing: "websites:run",
+		expect:  []string{"prog:pre", "run:pre", "websites:pre", "websites:run"},
+	}, {
+		name:    "failing PostRun hook",
+		argv:    []string{"prog", "run", "websites"},
+		failing: "prog:post",
+		expect:  []string{"prog:pre", "run:pre", "websites:pre", "websites:run", "prog:post"},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				options testOptions
+				calls   []string
+			)
+			err := newCLI(&options, &calls, tc.failing).Execute(context.Background(), tc.argv)
+			if (err != nil) != (tc.failing != "") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(calls, tc.expect) {
+				t.Fatalf("expected %v, got %v", tc.expect, calls)
+			}
+		})
+	}
+
+	t.Run("no hooks when printing help", func(t *testing.T) {
+		captureStdout(t)
+		var (
+			options testOptions
+			calls   []string
+		)
+		err := newCLI(&options, &calls, "").Execute(context.Background(), []string{"prog", "run", "websites", "--help"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(calls) != 0 {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("hooks see the parsed options", func(t *testing.T) {
+		var (
+			options testOptions
+			verbose bool
+		)
+		cli := newTestCLI(&options).SetPreRun(func(ctx context.Context, sc *SelectedCommand) error {
+			verbose = options.Global.Verbose
+			return nil
+		})
+		testSubcommand(cli, "list").SetRun(func(ctx context.Context, sc *SelectedCommand) error {
+			return nil
+		})
+		if err := cli.Execute(context.Background(), []string{"prog", "-v", "list"}); err != nil {
+			t.Fatal(err)
+		}
+		if !verbose {
+			t.Fatal("the PreRun hook did not see --verbose")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteFishCompletion writes on w a fish completion script for the given
+// parser, which must have been created using NewParser or MustNewParser.
+func WriteFishCompletion(w io.Writer, parser Parser) error {
+	root, err := newParserCompletionTree(parser)
+	if err != nil {
+		return err
+	}
+	return writeFishCompletion(w, root)
+}
+
+// WriteFishCompletion writes on w a fish completion script for the whole
+// commands tree based on `complete -c`. Unlike bash, fish shows the options
+// documentation and the subcommands descriptions inline. Like bash, the
+// script completes the values of the options implementing Completer by
+// running `prog __complete`. You should call this method on the toplevel
+// command. To use the script, save it as `prog.fish` (where `prog` is the
+// program name) inside a directory listed in $fish_complete_path.
+func (p *CommandParser) WriteFishCompletion(w io.Writer) error {
+	root, err := p.newCompletionTree()
+	if err != nil {
+		return err
+	}
+	return writeFishCompletion(w, root)
+}
+
+// writeFishCompletion writes the fish completion script for the given tree.
+func writeFishCompletion(w io.Writer, root *completionNode) error {
+	nodes := root.flatten()
+	prefix := "__" + shellIdentifier(root.name()) + "_complete"
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# fish completion for %s\n\n", root.name())
+
+	// 1. write a function that walks the words before the current one and
+	// prints whether we've seen `--` followed by the current command.
+	fmt.Fprintf(&sb, "function %s_state\n", prefix)
+	fmt.Fprintf(&sb, "\tset -l path %s\n", fishQuote(root.path()))
+	sb.WriteString("\tset -l dashdash 0\n")
+	sb.WriteString("\tset -l skip 0\n")
+	sb.WriteString("\tset -l words (commandline -opc)\n")
+	sb.WriteString("\tset -e words[1]\n")
+	sb.WriteString("\tfor word in $words\n")
+	sb.WriteString("\t\tif test $skip -eq 1\n\t\t\tset skip 0\n\t\t\tcontinue\n\t\tend\n")
+	sb.WriteString("\t\tif test $dashdash -eq 0\n")
+	sb.WriteString("\t\t\tswitch $word\n")
+	sb.WriteString("\t\t\t\tcase '--'\n\t\t\t\t\tset dashdash 1\n\t\t\t\t\tcontinue\n")
+	sb.WriteString("\t\t\t\tcase '-*'\n")
+	var patterns []string
+	for _, node := range nodes {
+		for _, word := range valueOptionWords(node.allOptions()) {
+			patterns = append(patterns, fishQuote(node.path()+" "+word))
+		}
+	}
+	if len(patterns) > 0 {
+		sb.WriteString("\t\t\t\t\tswitch \"$path $word\"\n")
+		fmt.Fprintf(&sb, "\t\t\t\t\t\tcase %s\n\t\t\t\t\t\t\tset skip 1\n", strings.Join(patterns, " "))
+		sb.WriteString("\t\t\t\t\tend\n")
+	}
+	sb.WriteString("\t\t\t\t\tcontinue\n")
+	sb.WriteString("\t\t\tend\n")
+	sb.WriteString("\t\tend\n")
+	sb.WriteString("\t\tswitch \"$path $word\"\n")
+	for _, node := range nodes {
+		for _, child := range node.children {
+			var patterns []string
+			for _, path := range child.aliasPaths() {
+				patterns = append(patterns, fishQuote(path))
+			}
+			fmt.Fprintf(&sb, "\t\t\tcase %s\n\t\t\t\tset path %s\n\t\t\t\tset dashdash 0\n",
+				strings.Join(patterns, " "), fishQuote(child.path()))
+		}
+	}
+	sb.WriteString("\t\tend\n")
+	sb.WriteString("\tend\n")
+	sb.WriteString("\techo \"$dashdash $path\"\n")
+	sb.WriteString("end\n\n")
+
+	// 2. write the predicates we use to decide whether to complete.
+	fmt.Fprintf(&sb, "function %s_options_at\n", prefix)
+	fmt.Fprintf(&sb, "\ttest (%s_state) = \"0 $argv[1]\"\n", prefix)
+	sb.WriteString("end\n\n")
+	fmt.Fprintf(&sb, "function %s_subcommands_at\n", prefix)
+	fmt.Fprintf(&sb, "\tstring match -q -- \"? $argv[1]\" (%s_state)\n", prefix)
+	sb.WriteString("end\n")
+
+	// 3. write the function that completes the values of the options
+	// implementing Completer using `prog __complete`.
+	var dynamic bool
+	for _, node := range nodes {
+		dynamic = dynamic || len(node.completerOptionWords()) > 0
+	}
+	if dynamic {
+		fmt.Fprintf(&sb, "\nfunction %s_values\n", prefix)
+		sb.WriteString("\tset -l words (commandline -opc)\n")
+		sb.WriteString("\t$words[1] __complete $words[2..-1] (commandline -ct) 2>/dev/null\n")
+		sb.WriteString("end\n")
+	}
+
+	// 4. write the completions for each command.
+	for _, node := range nodes {
+		sb.WriteString("\n")
+		condition := fishQuote(prefix + "_options_at " + fishQuote(node.path()))
+		for _, o := range uniqueOptions(node.allOptions()) {
+			fmt.Fprintf(&sb, "complete -c %s -n %s", fishQuote(root.name()), condition)
+			if o.Short != "" {
+				fmt.Fprintf(&sb, " -s %s", fishQuote(o.Short))
+			}
+			fmt.Fprintf(&sb, " -l %s", fishQuote(o.Long))
+			if !o.IsFlag {
+				sb.WriteString(" -r")
+			}
+			if node.completers[o.Long] {
+				fmt.Fprintf(&sb, " -f -a %s", fishQuote("("+prefix+"_values)"))
+			}
+			fmt.Fprintf(&sb, " -d %s\n", fishQuote(o.Doc))
+		}
+		condition = fishQuote(prefix + "_subcommands_at " + fishQuote(node.path()))
+		for _, child := range node.children {
+			fmt.Fprintf(&sb, "complete -c %s -n %s -f -a %s -d %s\n", fishQuote(root.name()),
+				condition, fishQuote(child.name()), fishQuote(child.description))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// fishQuote quotes a string for fish using single quotes.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
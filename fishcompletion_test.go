@@ -0,0 +1,57 @@
+package getoptx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteFishCompletion(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	var sb bytes.Buffer
+	if err := cli.WriteFishCompletion(&sb); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+	for _, expect := range []string{
+		"# fish completion for prog\n",
+		"\tset -l path 'prog'\n",
+		"\t\t\t\t\t\tcase 'prog --logfile' 'prog -L' 'prog list --logfile' 'prog list -L' 'prog list --id' 'prog run --logfile' 'prog run -L' 'prog run --input' 'prog run -i' 'prog run websites --input' 'prog run websites -i' 'prog run websites --logfile' 'prog run websites -L'\n" +
+			"\t\t\t\t\t\t\tset skip 1\n",
+		"\t\t\tcase 'prog run'\n\t\t\t\tset path 'prog run'\n\t\t\t\tset dashdash 0\n",
+		"complete -c 'prog' -n '__prog_complete_options_at \\'prog\\'' -s 'b' -l 'batch' -d 'emit JSON messages'\n",
+		"complete -c 'prog' -n '__prog_complete_options_at \\'prog\\'' -s 'L' -l 'logfile' -r -d 'file where to write logs'\n",
+		"complete -c 'prog' -n '__prog_complete_subcommands_at \\'prog\\'' -f -a 'run' -d 'Runs network measurements'\n",
+		"complete -c 'prog' -n '__prog_complete_options_at \\'prog run websites\\'' -s '3' -l 'force-http-3' -d 'forces using HTTP3'\n",
+	} {
+		if !strings.Contains(script, expect) {
+			t.Fatalf("cannot find %q inside:\n%s", expect, script)
+		}
+	}
+}
+
+func TestWriteFishCompletionParser(t *testing.T) {
+	var options struct {
+		Input string `doc:"the user's input"`
+	}
+	parser, err := NewParser(&options, SetProgramName("/usr/bin/prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteFishCompletion(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	expect := "complete -c 'prog' -n '__prog_complete_options_at \\'prog\\'' -l 'input' -r -d 'the user\\'s input'\n"
+	if !strings.HasSuffix(sb.String(), expect) {
+		t.Fatalf("cannot find %q at the end of:\n%s", expect, sb.String())
+	}
+}
+
+func TestFishQuote(t *testing.T) {
+	if got := fishQuote(`it's a \ test`); got != `'it\'s a \\ test'` {
+		t.Fatalf("unexpected quoted string: %s", got)
+	}
+}
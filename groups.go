@@ -0,0 +1,80 @@
+package getoptx
+
+// SetGroup sets the group of this command (e.g., "Measurement commands"). The
+// help message lists the subcommands without a group first, followed by the
+// subcommands of each group under a heading named after the group. Groups
+// appear in the order of their first subcommand in the alphabetically
+// sorted listing. A subcommand without a group inherits the group of its
+// closest ancestor having one. This method returns the command itself,
+// so that you can write, e.g.:
+//
+//     getoptx.Subcommand("run", "Runs measurements", &options.Run, ...).
+//       SetGroup("Measurement commands")
+func (p *CommandParser) SetGroup(group string) *CommandParser {
+	p.group = group
+	return p
+}
+
+// ListDirectSubcommandsOnly is a bit of config that causes the help message to
+// only list the direct subcommands of the current command, followed by a hint
+// explaining how to get help for each of them, rather than recursively listing
+// all the leaf subcommands. This is useful for large commands trees. You should
+// pass this config to CommandParser.Configure. This config has no effect
+// on parsers.
+func ListDirectSubcommandsOnly() Config {
+	return &listDirectSubcommandsOnly{}
+}
+
+type listDirectSubcommandsOnly struct{}
+
+func (c *listDirectSubcommandsOnly) visit(p *parserWrapper) {
+	// nothing
+}
+
+// directSubcommandsOnly returns whether we should only list the direct
+// subcommands. You should call this method on the toplevel command,
+// since we use the toplevel configuration.
+func (p *CommandParser) directSubcommandsOnly() bool {
+	return p.hasConfig(func(config Config) bool {
+		_, okay := config.(*listDirectSubcommandsOnly)
+		return okay
+	})
+}
+
+// subcommandGroup is a group of subcommands sharing the same group.
+type subcommandGroup struct {
+	// name is the group name or an empty string for the
+	// subcommands that do not belong to any group.
+	name string
+
+	// subcommands contains the subcommands in this group.
+	subcommands []HelpSubcommand
+}
+
+// subcommandGroups splits subcommands into groups. The first group contains
+// the subcommands without a group. The other groups follow in the order in
+// which they first appear inside subcommands.
+func subcommandGroups(subcommands []HelpSubcommand) (out []subcommandGroup) {
+	var (
+		groups    []subcommandGroup
+		index     = make(map[string]int)
+		ungrouped = subcommandGroup{name: ""}
+	)
+	for _, sc := range subcommands {
+		if sc.Group == "" {
+			ungrouped.subcommands = append(ungrouped.subcommands, sc)
+			continue
+		}
+		idx, found := index[sc.Group]
+		if !found {
+			idx = len(groups)
+			index[sc.Group] = idx
+			groups = append(groups, subcommandGroup{name: sc.Group})
+		}
+		groups[idx].subcommands = append(groups[idx].subcommands, sc)
+	}
+	if len(ungrouped.subcommands) > 0 {
+		out = append(out, ungrouped)
+	}
+	return append(out, groups...)
+}
@@ -0,0 +1,160 @@
+package getoptx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubcommandGroups(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+
+	// newCLI is like newTestCLI but assigns `run` and `list` to groups
+	// and assigns `run websites` to the given group, if not empty.
+	newCLI := func(options *testOptions, websitesGroup string, config ...Config) *CommandParser {
+		cli := newTestCLI(options, config...)
+		run := testSubcommand(cli, "run").SetGroup("Measurement commands")
+		testSubcommand(run, "websites").SetGroup(websitesGroup)
+		testSubcommand(cli, "list").SetGroup("Result management")
+		return cli
+	}
+
+	type testcase struct {
+		name          string
+		websitesGroup string
+		config        []Config
+		argv          []string
+		expect        string
+	}
+
+	testcases := []testcase{{
+		name:          "recursive listing",
+		websitesGroup: "",
+		config:        nil,
+		argv:          []string{"prog", "--help"},
+		expect: `Subcommands:
+
+  help
+             Prints generic or command-specific help.
+
+Result management:
+
+  list
+             Lists network measurements.
+
+Measurement commands:
+
+  run websites
+             Tests websites for censorship.
+
+`,
+	}, {
+		name:          "recursive listing with a nested group",
+		websitesGroup: "Web commands",
+		config:        nil,
+		argv:          []string{"prog", "--help"},
+		expect: `Subcommands:
+
+  help
+             Prints generic or command-specific help.
+
+Result management:
+
+  list
+             Lists network measurements.
+
+Web commands:
+
+  run websites
+             Tests websites for censorship.
+
+`,
+	}, {
+		name:          "direct subcommands only",
+		websitesGroup: "Web commands",
+		config:        []Config{ListDirectSubcommandsOnly()},
+		argv:          []string{"prog", "--help"},
+		expect: `Subcommands:
+
+  help
+             Prints generic or command-specific help.
+
+Result management:
+
+  list
+             Lists network measurements.
+
+Measurement commands:
+
+  run
+             Runs network measurements.
+
+Run 'prog help <subcommand>' for more information.
+
+`,
+	}, {
+		name:          "direct subcommands only of a subcommand",
+		websitesGroup: "Web commands",
+		config:        []Config{ListDirectSubcommandsOnly()},
+		argv:          []string{"prog", "run", "--help"},
+		expect: `
+Web commands:
+
+  websites
+             Tests websites for censorship.
+
+Run 'prog help run <subcommand>' for more information.
+
+`,
+	}, {
+		name:          "following the hint",
+		websitesGroup: "Web commands",
+		config:        []Config{ListDirectSubcommandsOnly()},
+		argv:          []string{"prog", "help", "run"},
+		expect: `
+Web commands:
+
+  websites
+             Tests websites for censorship.
+
+Run 'prog help run <subcommand>' for more information.
+
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options testOptions
+			cli := newCLI(&options, tc.websitesGroup, tc.config...)
+			stdout := captureStdout(t)
+			if _, err := cli.Getopt(tc.argv); err != nil {
+				t.Fatal(err)
+			}
+			if output := stdout(); !strings.HasSuffix(output, tc.expect) {
+				t.Fatalf("expected output ending with:\n%s\ngot:\n%s", tc.expect, output)
+			}
+		})
+	}
+}
+
+func TestSubcommandGroupsOrder(t *testing.T) {
+	subcommands := []HelpSubcommand{
+		{Names: []string{"a"}, Group: "Second"},
+		{Names: []string{"b"}, Group: ""},
+		{Names: []string{"c"}, Group: "First"},
+		{Names: []string{"d"}, Group: "Second"},
+	}
+	groups := subcommandGroups(subcommands)
+	var got []string
+	for _, group := range groups {
+		var names []string
+		for _, sc := range group.subcommands {
+			names = append(names, sc.Names[0])
+		}
+		got = append(got, group.name+":"+strings.Join(names, ","))
+	}
+	expect := ":b Second:a,d First:c"
+	if strings.Join(got, " ") != expect {
+		t.Fatalf("expected %q, got %q", expect, strings.Join(got, " "))
+	}
+}
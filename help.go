@@ -0,0 +1,375 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// HelpRenderer renders the help message of a Parser or of a command.
+type HelpRenderer interface {
+	// RenderHelp writes on w the help message described by model.
+	RenderHelp(w io.Writer, model *HelpModel) error
+}
+
+// HelpModel is the structured description of a Parser or of a
+// command that we pass to a HelpRenderer.
+type HelpModel struct {
+	// IsCommand is true when the model describes a command created
+	// using Command, Subcommand, or LeafSubcommand, and false when
+	// the model describes a parser created using NewParser.
+	IsCommand bool
+
+	// Chain contains the commands from the toplevel command to the
+	// current command, which is the last entry. When the model describes
+	// a parser created using NewParser, there is just a single entry.
+	Chain []HelpCommand
+
+	// Description is the current command's description.
+	Description string
+
+	// Positional is the placeholder for the positional arguments (e.g.,
+	// "<argument>"). It's empty if we do not accept positional arguments.
+	Positional string
+
+	// Subcommands contains all the leaf subcommands reachable from
+	// the current command sorted alphabetically or, when using
+	// ListDirectSubcommandsOnly, just the direct subcommands.
+	Subcommands []HelpSubcommand
+
+	// DirectSubcommandsOnly indicates that Subcommands only contains
+	// the direct subcommands (see ListDirectSubcommandsOnly).
+	DirectSubcommandsOnly bool
+
+	// LongDescription is the current command's long description, which
+	// may contain several paragraphs separated by empty lines.
+	LongDescription string
+
+	// Examples contains the current command's usage examples.
+	Examples []HelpExample
+
+	// Epilog is the text to print at the end of the help message, which
+	// may contain several paragraphs separated by empty lines.
+	Epilog string
+}
+
+// HelpCommand describes a command inside HelpModel.Chain.
+type HelpCommand struct {
+	// Name is the command name.
+	Name string
+
+	// Options contains the command options.
+	Options []HelpOption
+}
+
+// HelpOption describes a command line option.
+type HelpOption struct {
+	// Long is the long option name without dashes (e.g., "verbose").
+	Long string `json:"long"`
+
+	// Short is the short option name without dash (e.g., "v"). It is
+	// empty if the option does not have a short name.
+	Short string `json:"short,omitempty"`
+
+	// Doc is the option documentation from the `doc:"..."` tag, where
+	// we have already resolved references to catalog messages.
+	Doc string `json:"doc"`
+
+	// Required indicates whether the option is required.
+	Required bool `json:"required"`
+
+	// IsFlag indicates whether the option does not take a value.
+	IsFlag bool `json:"is_flag"`
+
+	// Type is the Go type of the option (e.g., "string", "[]string").
+	Type string `json:"type"`
+
+	// Default is the string representation of the option's value
+	// when we constructed the parser (e.g., "false", "0").
+	Default string `json:"default"`
+
+	// Metavar is the placeholder of the option's value (e.g., "URL",
+	// "int", "string..."). It is empty if the option is a flag.
+	Metavar string `json:"metavar,omitempty"`
+
+	// Section is the option section from the `section:"..."` tag. It is
+	// empty if the option does not belong to any section.
+	Section string `json:"section,omitempty"`
+
+	// Persistent indicates whether the option is persistent, i.e., whether
+	// the user can also specify it after the name of any subcommand.
+	Persistent bool `json:"persistent,omitempty"`
+}
+
+// HelpSubcommand describes a subcommand.
+type HelpSubcommand struct {
+	// Names contains the path from the current command to the subcommand
+	// (e.g., []string{"run", "websites"}).
+	Names []string
+
+	// Aliases contains the alternative names of the subcommand.
+	Aliases []string
+
+	// Description is the subcommand description.
+	Description string
+
+	// Group is the subcommand group, which is empty
+	// if the subcommand does not belong to any group.
+	Group string
+}
+
+// HelpExample is an usage example of a command.
+type HelpExample struct {
+	// Command is the example's command line.
+	Command string `json:"command"`
+
+	// Explanation explains what the command line does.
+	Explanation string `json:"explanation"`
+}
+
+// SetHelpRenderer is a bit of config that replaces the default renderer used
+// to print help messages, which you can obtain using DefaultHelpRenderer.
+func SetHelpRenderer(renderer HelpRenderer) Config {
+	return &setHelpRenderer{renderer: renderer}
+}
+
+type setHelpRenderer struct {
+	renderer HelpRenderer
+}
+
+func (c *setHelpRenderer) visit(p *parserWrapper) {
+	p.renderer = c.renderer
+}
+
+// DefaultHelpRenderer returns the default HelpRenderer.
+func DefaultHelpRenderer() HelpRenderer {
+	return &defaultHelpRenderer{layout: &helpLayout{}, messages: newMessages(nil)}
+}
+
+// TemplateHelpRenderer returns a HelpRenderer that executes the given
+// template passing it the *HelpModel as its data.
+func TemplateHelpRenderer(t *template.Template) HelpRenderer {
+	return &templateHelpRenderer{t: t}
+}
+
+type templateHelpRenderer struct {
+	t *template.Template
+}
+
+// RenderHelp implements HelpRenderer.RenderHelp.
+func (r *templateHelpRenderer) RenderHelp(w io.Writer, model *HelpModel) error {
+	return r.t.Execute(w, model)
+}
+
+// defaultHelpRenderer is the default HelpRenderer.
+type defaultHelpRenderer struct {
+	// layout controls the help layout.
+	layout *helpLayout
+
+	// messages contains the messages to print.
+	messages *messages
+}
+
+// RenderHelp implements HelpRenderer.RenderHelp.
+func (r *defaultHelpRenderer) RenderHelp(w io.Writer, model *HelpModel) error {
+	layout := r.layout.resolve(w)
+	if !model.IsCommand {
+		r.printParserBriefUsage(w, model, layout)
+		fmt.Fprintf(w, "\n")
+		for _, entry := range model.Chain {
+			sections := optionSections(entry.Options)
+			if len(sections) <= 0 {
+				fmt.Fprintf(w, "%s\n\n", layout.heading(r.messages.get(MsgOptions)+":"))
+			}
+			for _, section := range sections {
+				fmt.Fprintf(w, "%s\n\n", layout.heading(r.sectionHeading(section.name)+":"))
+				r.printOptions(w, section.options, layout)
+			}
+		}
+		return nil
+	}
+	r.printBriefUsage(w, model, layout)
+	r.printDescription(w, model.Description, layout)
+	for _, entry := range model.Chain {
+		for _, section := range optionSections(entry.Options) {
+			heading := r.messages.sprintf(MsgOptionsFor, r.sectionHeading(section.name), entry.Name)
+			fmt.Fprintf(w, "%s\n\n", layout.heading(heading))
+			r.printOptions(w, section.options, layout)
+		}
+	}
+	r.printSubcommands(w, model, layout)
+	r.printLongDescription(w, model.LongDescription, layout)
+	r.printExamples(w, model.Examples, layout)
+	r.printEpilog(w, model.Epilog, layout)
+	return nil
+}
+
+// printParserBriefUsage prints brief usage for a parser.
+func (r *defaultHelpRenderer) printParserBriefUsage(w io.Writer, model *HelpModel, layout *helpLayout) {
+	var words []string
+	if len(model.Chain) > 0 {
+		entry := model.Chain[len(model.Chain)-1]
+		words = append(words, entry.Name, "[options]")
+		words = append(words, requiredOptionsSynopsis(entry.Options)...)
+	}
+	fmt.Fprintf(w, "\n%s %s %s\n", layout.heading(r.messages.get(MsgUsage)),
+		strings.Join(words, " "), model.Positional)
+}
+
+// printBriefUsage prints brief usage for a command.
+func (r *defaultHelpRenderer) printBriefUsage(w io.Writer, model *HelpModel, layout *helpLayout) {
+	fmt.Fprintf(w, "\n%s %s\n", layout.heading(r.messages.get(MsgUsage)), usageLine(model))
+}
+
+// usageLine returns the usage line of a command (e.g., "prog [options]
+// run [options] <subcommand> [...]").
+func usageLine(model *HelpModel) string {
+	var words []string
+	for _, entry := range model.Chain {
+		words = append(words, entry.Name)
+		if len(entry.Options) > 0 {
+			words = append(words, "[options]")
+		}
+		words = append(words, requiredOptionsSynopsis(entry.Options)...)
+	}
+	if model.Positional != "" {
+		words = append(words, model.Positional)
+	}
+	return strings.Join(words, " ")
+}
+
+// requiredOptionsSynopsis returns the synopsis of each required option (e.g.,
+// "--input URL"), which we include in the usage string.
+func requiredOptionsSynopsis(options []HelpOption) (out []string) {
+	for _, o := range options {
+		if o.Required {
+			out = append(out, optionSynopsis(o))
+		}
+	}
+	return
+}
+
+// printDescription prints the command's description.
+func (r *defaultHelpRenderer) printDescription(w io.Writer, doc string, layout *helpLayout) {
+	fmt.Fprintf(w, "\n")
+	if !strings.HasSuffix(doc, ".") {
+		doc += "."
+	}
+	layout.printDescription(w, doc)
+	fmt.Fprintf(w, "\n")
+}
+
+// printOptions prints the given options.
+func (r *defaultHelpRenderer) printOptions(w io.Writer, options []HelpOption, layout *helpLayout) {
+	for _, o := range options {
+		if o.Short != "" {
+			fmt.Fprintf(w, "  %s, %s", layout.highlight("-"+o.Short), layout.highlight("--"+o.Long))
+		} else {
+			fmt.Fprintf(w, "      %s", layout.highlight("--"+o.Long))
+		}
+		if !o.IsFlag {
+			fmt.Fprintf(w, " %s", o.Metavar)
+		}
+		fmt.Fprintf(w, "\n")
+		doc := optionDoc(o, r.messages)
+		layout.printDoc(w, doc)
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// sectionHeading returns the heading of the given options section.
+func (r *defaultHelpRenderer) sectionHeading(section string) string {
+	if section == "" {
+		return r.messages.get(MsgOptions)
+	}
+	return r.messages.sprintf(MsgSectionOptions, section)
+}
+
+// optionDoc returns the full documentation of an option.
+func optionDoc(o HelpOption, m *messages) string {
+	doc := o.Doc
+	if !strings.HasSuffix(doc, ".") {
+		doc += "."
+	}
+	if o.Required {
+		doc += " " + m.get(MsgMandatory)
+	}
+	return doc
+}
+
+// printSubcommands prints the subcommands grouped by group.
+func (r *defaultHelpRenderer) printSubcommands(w io.Writer, model *HelpModel, layout *helpLayout) {
+	if len(model.Subcommands) <= 0 {
+		return
+	}
+	for _, group := range subcommandGroups(model.Subcommands) {
+		heading := r.messages.get(MsgSubcommands)
+		if group.name != "" {
+			heading = group.name + ":"
+		}
+		fmt.Fprintf(w, "%s\n\n", layout.heading(heading))
+		for _, sc := range group.subcommands {
+			fmt.Fprintf(w, "  %s", layout.highlight(strings.Join(sc.Names, " ")))
+			if len(sc.Aliases) > 0 {
+				fmt.Fprintf(w, " (%s)", strings.Join(sc.Aliases, ", "))
+			}
+			fmt.Fprintf(w, "\n")
+			doc := sc.Description
+			if !strings.HasSuffix(doc, ".") {
+				doc += "."
+			}
+			layout.printDoc(w, doc)
+			fmt.Fprintf(w, "\n")
+		}
+	}
+	if model.DirectSubcommandsOnly {
+		// We point the user to the `help` subcommand (e.g., `prog help run
+		// <subcommand>`), which prints help without checking required options.
+		var names []string
+		for idx, entry := range model.Chain {
+			names = append(names, entry.Name)
+			if idx == 0 {
+				names = append(names, "help")
+			}
+		}
+		layout.printDescription(w, r.messages.sprintf(MsgSubcommandsHint, strings.Join(names, " ")))
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// printLongDescription prints the command's long description, if any.
+func (r *defaultHelpRenderer) printLongDescription(w io.Writer, text string, layout *helpLayout) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(w, "%s\n\n", layout.heading(r.messages.get(MsgDescription)))
+	layout.printParagraphs(w, text, 2)
+	fmt.Fprintf(w, "\n")
+}
+
+// printExamples prints the command's usage examples, if any.
+func (r *defaultHelpRenderer) printExamples(w io.Writer, examples []HelpExample, layout *helpLayout) {
+	if len(examples) <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s\n\n", layout.heading(r.messages.get(MsgExamples)))
+	for _, example := range examples {
+		fmt.Fprintf(w, "  %s\n", layout.highlight(example.Command))
+		doc := example.Explanation
+		if !strings.HasSuffix(doc, ".") {
+			doc += "."
+		}
+		layout.printDoc(w, doc)
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// printEpilog prints the command's epilog, if any.
+func (r *defaultHelpRenderer) printEpilog(w io.Writer, text string, layout *helpLayout) {
+	if text == "" {
+		return
+	}
+	layout.printParagraphs(w, text, 0)
+	fmt.Fprintf(w, "\n")
+}
@@ -0,0 +1,177 @@
+package getoptx
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+)
+
+// readGolden reads the given file from testdata/help. We generated these
+// files using the original implementation, before HelpRenderer existed,
+// so they ensure that the default renderer preserves the old output.
+func readGolden(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", "help", name+".golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDefaultHelpRendererGolden(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+
+	t.Run("parser", func(t *testing.T) {
+		var options testOptions
+		parser, err := NewParser(&options.Global, SetProgramName("prog"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sb bytes.Buffer
+		parser.PrintUsage(&sb)
+		if expect := readGolden(t, "parser"); sb.String() != expect {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expect, sb.String())
+		}
+	})
+
+	type testcase struct {
+		name   string
+		argv   []string
+		golden string
+	}
+
+	testcases := []testcase{{
+		name:   "toplevel",
+		argv:   []string{"prog", "--help"},
+		golden: "root",
+	}, {
+		name:   "toplevel without arguments",
+		argv:   []string{"prog"},
+		golden: "root",
+	}, {
+		name:   "intermediate command",
+		argv:   []string{"prog", "run", "--help"},
+		golden: "run",
+	}, {
+		name:   "leaf command",
+		argv:   []string{"prog", "run", "websites", "-h"},
+		golden: "run-websites",
+	}, {
+		name:   "leaf command with positional arguments",
+		argv:   []string{"prog", "list", "--help"},
+		golden: "list",
+	}, {
+		name:   "help subcommand",
+		argv:   []string{"prog", "help", "run"},
+		golden: "help-run",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout := captureStdout(t)
+			var options testOptions
+			cli := newTestCLI(&options)
+			selected, err := cli.Getopt(tc.argv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, okay := selected.Options().(*HasPrintedHelp); !okay {
+				t.Fatalf("expected *HasPrintedHelp, got %T", selected.Options())
+			}
+			if got, expect := stdout(), readGolden(t, tc.golden); got != expect {
+				t.Fatalf("expected:\n%s\ngot:\n%s", expect, got)
+			}
+		})
+	}
+}
+
+// recordingHelpRenderer is a HelpRenderer that records the model.
+type recordingHelpRenderer struct {
+	model *HelpModel
+}
+
+// RenderHelp implements HelpRenderer.RenderHelp.
+func (r *recordingHelpRenderer) RenderHelp(w io.Writer, model *HelpModel) error {
+	r.model = model
+	_, err := io.WriteString(w, "custom help\n")
+	return err
+}
+
+func TestSetHelpRenderer(t *testing.T) {
+	withProgramName(t, "prog")
+	stdout := captureStdout(t)
+	renderer := &recordingHelpRenderer{}
+	var options testOptions
+	cli := newTestCLI(&options, SetHelpRenderer(renderer))
+	if _, err := cli.Getopt([]string{"prog", "run", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout(); got != "custom help\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	model := renderer.model
+	if model == nil || !model.IsCommand || model.Description != "Runs network measurements" {
+		t.Fatalf("unexpected model: %+v", model)
+	}
+	var names []string
+	for _, entry := range model.Chain {
+		names = append(names, entry.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"prog", "run"}) {
+		t.Fatalf("unexpected chain: %v", names)
+	}
+	expect := HelpOption{
+		Long:       "input",
+		Short:      "i",
+		Doc:        "add URL to measure",
+		Type:       "[]string",
+		Metavar:    "value",
+		Persistent: true,
+	}
+	if options := model.Chain[1].Options; len(options) != 1 || options[0] != expect {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+	if len(model.Subcommands) != 1 || !reflect.DeepEqual(model.Subcommands[0].Names, []string{"websites"}) {
+		t.Fatalf("unexpected subcommands: %+v", model.Subcommands)
+	}
+}
+
+func TestTemplateHelpRenderer(t *testing.T) {
+	var options testOptions
+	tmpl := template.Must(template.New("help").Parse(
+		"{{range .Chain}}{{range .Options}}--{{.Long}}{{if not .IsFlag}} {{.Metavar}}{{end}}\n{{end}}{{end}}"))
+	parser, err := NewParser(&options.Global, SetHelpRenderer(TemplateHelpRenderer(tmpl)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	parser.PrintUsage(&sb)
+	if expect := "--batch\n--logfile value\n--verbose\n"; sb.String() != expect {
+		t.Fatalf("expected %q, got %q", expect, sb.String())
+	}
+}
+
+func TestDefaultHelpRenderer(t *testing.T) {
+	model := &HelpModel{
+		Chain: []HelpCommand{{
+			Name: "prog",
+			Options: []HelpOption{{
+				Long:   "verbose",
+				Doc:    "run in verbose mode",
+				IsFlag: true,
+			}},
+		}},
+	}
+	var sb bytes.Buffer
+	if err := DefaultHelpRenderer().RenderHelp(&sb, model); err != nil {
+		t.Fatal(err)
+	}
+	expect := "\nUsage: prog [options] \n\nOptions:\n\n      --verbose\n             run in verbose mode.\n\n"
+	if sb.String() != expect {
+		t.Fatalf("expected %q, got %q", expect, sb.String())
+	}
+}
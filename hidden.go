@@ -0,0 +1,85 @@
+package getoptx
+
+import (
+	"os"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// SetHidden controls whether this command is hidden. A hidden command works
+// as usual but we do not list it in the help message, in the generated docs,
+// and in the completions. This is useful for internal commands (e.g., `debug`
+// or `selftest`) that would otherwise clutter the help message. The user can
+// list the hidden commands by using `--help-all` rather than `--help`, which
+// we register for all the commands with hidden subcommands, or by setting
+// the environment variable configured using SetHelpAllEnvironmentVariable.
+// This method returns the command itself, so that you can write, e.g.:
+//
+//     getoptx.LeafSubcommand("selftest", "Runs the self tests", &options.Selftest).
+//       SetHidden(true)
+func (p *CommandParser) SetHidden(hidden bool) *CommandParser {
+	p.hidden = hidden
+	return p
+}
+
+// SetHelpAllEnvironmentVariable is a bit of config that configures the name
+// of an environment variable (e.g., "PROG_HELP_ALL") that, when set to a
+// nonempty value, causes the help message to also list the hidden commands
+// (see CommandParser.SetHidden). You should pass this config to
+// CommandParser.Configure. This config has no effect on parsers.
+func SetHelpAllEnvironmentVariable(name string) Config {
+	return &setHelpAllEnvironmentVariable{name: name}
+}
+
+type setHelpAllEnvironmentVariable struct {
+	name string
+}
+
+func (c *setHelpAllEnvironmentVariable) visit(p *parserWrapper) {
+	// nothing
+}
+
+// showHiddenFromEnvironment returns whether the environment variable configured
+// using SetHelpAllEnvironmentVariable is set. You should call this method on
+// the toplevel command, since we use the toplevel configuration.
+func (p *CommandParser) showHiddenFromEnvironment() bool {
+	var name string
+	for _, config := range p.configs {
+		if c, okay := config.(*setHelpAllEnvironmentVariable); okay {
+			name = c.name
+		}
+	}
+	return name != "" && os.Getenv(name) != ""
+}
+
+// hasHiddenSubcommands returns whether this command has hidden subcommands
+// at any depth, except for the internal `__complete` subcommand.
+func (p *CommandParser) hasHiddenSubcommands() bool {
+	for _, sc := range p.subcommands {
+		if _, okay := sc.options.(*subcommandComplete); okay {
+			continue
+		}
+		if sc.hidden || sc.hasHiddenSubcommands() {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeAddHelpAllFlag attempts to register --help-all. If the user has
+// already configured --help-all we'll just do nothing.
+func (p *parserWrapper) maybeAddHelpAllFlag(helpAll *bool) bool {
+	var found bool
+	p.set.VisitAll(func(o getopt.Option) {
+		found = found || o.LongName() == "help-all"
+	})
+	if found {
+		return false
+	}
+	doc := "msgid:" + MsgHelpAllOption
+	p.set.FlagLong(helpAll, "help-all", 0, doc).SetFlag()
+	p.docs["help-all"] = doc
+	p.defaults["help-all"] = "false"
+	p.types["help-all"] = "bool"
+	return true
+}
@@ -0,0 +1,124 @@
+package getoptx
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHiddenSubcommands(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+	const listing = "\n  list\n             Lists network measurements.\n"
+
+	// newCLI is like newTestCLI but `list` is hidden.
+	newCLI := func(options *testOptions) *CommandParser {
+		cli := newTestCLI(options, SetHelpAllEnvironmentVariable("PROG_HELP_ALL"))
+		testSubcommand(cli, "list").SetHidden(true)
+		return cli
+	}
+
+	t.Run("dispatch", func(t *testing.T) {
+		var options testOptions
+		sc, err := newCLI(&options).Getopt([]string{"prog", "list", "--id", "7"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc.Options() != &options.List || options.List.ID != 7 {
+			t.Fatalf("unexpected selected command: %+v", sc)
+		}
+	})
+
+	type testcase struct {
+		name   string
+		argv   []string
+		env    string
+		listed bool
+	}
+
+	testcases := []testcase{{
+		name:   "help",
+		argv:   []string{"prog", "--help"},
+		env:    "",
+		listed: false,
+	}, {
+		name:   "help-all",
+		argv:   []string{"prog", "--help-all"},
+		env:    "",
+		listed: true,
+	}, {
+		name:   "help with environment variable",
+		argv:   []string{"prog", "--help"},
+		env:    "1",
+		listed: true,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PROG_HELP_ALL", tc.env)
+			var options testOptions
+			cli := newCLI(&options)
+			stdout := captureStdout(t)
+			if _, err := cli.Getopt(tc.argv); err != nil {
+				t.Fatal(err)
+			}
+			output := stdout()
+			if strings.Contains(output, listing) != tc.listed {
+				t.Fatalf("unexpected listing of hidden commands:\n%s", output)
+			}
+			if !strings.Contains(output, "\n  run websites\n") {
+				t.Fatalf("cannot find the run websites command inside:\n%s", output)
+			}
+			if strings.Contains(output, "__complete") {
+				t.Fatalf("unexpected internal command inside:\n%s", output)
+			}
+		})
+	}
+
+	t.Run("help-all is only available with hidden subcommands", func(t *testing.T) {
+		captureStderr(t)
+		var options testOptions
+		if _, err := newTestCLI(&options).Getopt([]string{"prog", "--help-all"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("not suggested", func(t *testing.T) {
+		captureStderr(t)
+		var options testOptions
+		_, err := newCLI(&options).Getopt([]string{"prog", "lsit"})
+		var unknown *UnknownSubcommandError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected *UnknownSubcommandError, got %T", err)
+		}
+		if len(unknown.Suggestions) != 0 {
+			t.Fatalf("unexpected suggestions: %v", unknown.Suggestions)
+		}
+		if expect := []string{"help", "run"}; !reflect.DeepEqual(unknown.Valid, expect) {
+			t.Fatalf("expected %v, got %v", expect, unknown.Valid)
+		}
+	})
+
+	t.Run("not completed", func(t *testing.T) {
+		var options testOptions
+		expect := []string{"help", "run"}
+		if got := newCLI(&options).complete([]string{""}); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("expected %v, got %v", expect, got)
+		}
+	})
+
+	t.Run("not documented", func(t *testing.T) {
+		var options testOptions
+		var sb bytes.Buffer
+		if err := newCLI(&options).WriteJSON(&sb); err != nil {
+			t.Fatal(err)
+		}
+		cd := decodeCommandDescription(t, sb.Bytes())
+		expect := [][]string{{"prog"}, {"prog", "run"}, {"prog", "run", "websites"}}
+		if got := commandPaths(cd); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("expected %v, got %v", expect, got)
+		}
+	})
+}
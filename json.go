@@ -0,0 +1,182 @@
+package getoptx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// CommandDescription is the machine-readable description of a Parser or
+// of a command that WriteJSON and CommandParser.WriteJSON serialize.
+type CommandDescription struct {
+	// Name is the command name.
+	Name string `json:"name"`
+
+	// Path contains the names of the commands from the toplevel
+	// command to this command (e.g., ["prog", "run", "websites"]).
+	Path []string `json:"path"`
+
+	// Aliases contains the alternative names of the command.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Description is the command description.
+	Description string `json:"description,omitempty"`
+
+	// Group is the command group.
+	Group string `json:"group,omitempty"`
+
+	// Options contains the command options.
+	Options []HelpOption `json:"options"`
+
+	// InheritedOptions contains the persistent options of the ancestor
+	// commands, which the user can also specify after the command name.
+	InheritedOptions []HelpOption `json:"inherited_options,omitempty"`
+
+	// Positional describes the accepted positional arguments.
+	Positional PositionalDescription `json:"positional"`
+
+	// LongDescription is the command long description.
+	LongDescription string `json:"long_description,omitempty"`
+
+	// Examples contains the command usage examples.
+	Examples []HelpExample `json:"examples,omitempty"`
+
+	// Epilog is the text printed at the end of the help message.
+	Epilog string `json:"epilog,omitempty"`
+
+	// Subcommands contains the subcommands.
+	Subcommands []*CommandDescription `json:"subcommands,omitempty"`
+}
+
+// PositionalDescription describes the accepted positional arguments.
+type PositionalDescription struct {
+	// Placeholder is the placeholder used in the usage string.
+	Placeholder string `json:"placeholder,omitempty"`
+
+	// Min is the minimum number of positional arguments.
+	Min int `json:"min"`
+
+	// Max is the maximum number of positional arguments or -1
+	// if there is no upper bound on their number.
+	Max int `json:"max"`
+}
+
+// newPositionalDescription creates a new PositionalDescription.
+func newPositionalDescription(pac *positionalArgumentsChecker, placeholder string) PositionalDescription {
+	pd := PositionalDescription{
+		Placeholder: placeholder,
+		Min:         maxInt(pac.minArgs, 0),
+		Max:         pac.maxArgs,
+	}
+	if pac.maxArgs == math.MaxInt {
+		pd.Max = -1
+	}
+	return pd
+}
+
+// WriteJSON writes on w the JSON serialization of the CommandDescription of
+// the given parser, which must have been created using NewParser.
+func WriteJSON(w io.Writer, parser Parser) error {
+	pw, okay := parser.(*parserWrapper)
+	if !okay {
+		return fmt.Errorf("getoptx: parser not created using NewParser")
+	}
+	model := pw.newHelpModel()
+	name := manPageNameFromNames([]string{model.Chain[0].Name})
+	return writeJSON(w, &CommandDescription{
+		Name:       name,
+		Path:       []string{name},
+		Options:    model.Chain[0].Options,
+		Positional: newPositionalDescription(pw.pac, model.Positional),
+	})
+}
+
+// WriteJSON writes on w the JSON serialization of the CommandDescription of
+// this command and of all its subcommands. You should call this method on
+// the toplevel command. The user can also obtain the same JSON description
+// by using `--help=json` on the command line, in which case we describe
+// the selected command and its subcommands.
+func (p *CommandParser) WriteJSON(w io.Writer) error {
+	return writeJSON(w, p.describe([]*CommandParser{p}))
+}
+
+// describe returns the CommandDescription of the last command in the chain.
+func (p *CommandParser) describe(chain []*CommandParser) *CommandDescription {
+	model := p.newHelpModel(chain)
+	cd := &CommandDescription{
+		Name:        p.name,
+		Aliases:     p.aliases,
+		Description: model.Description,
+		Group:       p.group,
+		Options:     model.Chain[len(model.Chain)-1].Options,
+		Positional:  newPositionalDescription(p.pac, model.Positional),
+
+		InheritedOptions: inheritedHelpOptions(model.Chain),
+
+		LongDescription: model.LongDescription,
+		Examples:        model.Examples,
+		Epilog:          model.Epilog,
+	}
+	for _, entry := range chain {
+		cd.Path = append(cd.Path, entry.name)
+	}
+	cd.Path[0] = manPageNameFromNames(cd.Path[:1])
+	if len(chain) < 2 {
+		cd.Name = cd.Path[0]
+	}
+	if len(p.subcommands) > 0 {
+		cd.Positional.Min, cd.Positional.Max = 1, -1
+	}
+	for _, sc := range p.documentedSubcommands() {
+		subchain := append([]*CommandParser{}, chain...)
+		subchain = append(subchain, sc)
+		cd.Subcommands = append(cd.Subcommands, sc.describe(subchain))
+	}
+	return cd
+}
+
+// writeJSON writes the indented JSON serialization of cd on w.
+func writeJSON(w io.Writer, cd *CommandDescription) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false) // we have "<argument>" placeholders
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(cd)
+}
+
+// helpValue is the value of the -h/--help flag. Besides the usual
+// `-h` and `--help` forms, we also accept `--help=json`.
+type helpValue struct {
+	// enabled is set to true when the user requests help.
+	enabled *bool
+
+	// format is the requested help format (either "" or "json").
+	format *string
+}
+
+var _ getopt.Value = &helpValue{}
+
+// Set implements getopt.Value.Set.
+func (v *helpValue) Set(value string, opt getopt.Option) error {
+	switch value {
+	case "", "true", "text":
+		*v.enabled, *v.format = true, ""
+	case "json":
+		*v.enabled, *v.format = true, "json"
+	case "false":
+		*v.enabled, *v.format = false, ""
+	default:
+		return fmt.Errorf("invalid help format for %s: %q", opt.Name(), value)
+	}
+	return nil
+}
+
+// String implements getopt.Value.String.
+func (v *helpValue) String() string {
+	if *v.enabled {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,222 @@
+package getoptx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWriteJSONParser(t *testing.T) {
+	var options struct {
+		Input   []string `doc:"add URL to measure" metavar:"URL" required:"true"`
+		Verbose bool     `doc:"run in verbose mode" short:"v"`
+	}
+	parser, err := NewParser(&options, SetProgramName("/usr/bin/prog"), JustOnePositionalArgument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteJSON(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	expect := `{
+  "name": "prog",
+  "path": [
+    "prog"
+  ],
+  "options": [
+    {
+      "long": "input",
+      "doc": "add URL to measure",
+      "required": true,
+      "is_flag": false,
+      "type": "[]string",
+      "default": "",
+      "metavar": "URL"
+    },
+    {
+      "long": "verbose",
+      "short": "v",
+      "doc": "run in verbose mode",
+      "required": false,
+      "is_flag": true,
+      "type": "bool",
+      "default": "false"
+    }
+  ],
+  "positional": {
+    "placeholder": "[parameters ...]",
+    "min": 1,
+    "max": 1
+  }
+}
+`
+	if sb.String() != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, sb.String())
+	}
+
+	if err := WriteJSON(&sb, fakeParser{}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// decodeCommandDescription decodes the given JSON description.
+func decodeCommandDescription(t *testing.T, data []byte) *CommandDescription {
+	var cd CommandDescription
+	if err := json.Unmarshal(data, &cd); err != nil {
+		t.Fatal(err)
+	}
+	return &cd
+}
+
+// commandPaths returns the path of cd and of its descendants.
+func commandPaths(cd *CommandDescription) (out [][]string) {
+	out = append(out, cd.Path)
+	for _, sc := range cd.Subcommands {
+		out = append(out, commandPaths(sc)...)
+	}
+	return
+}
+
+func TestCommandWriteJSON(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	var sb bytes.Buffer
+	if err := cli.WriteJSON(&sb); err != nil {
+		t.Fatal(err)
+	}
+	cd := decodeCommandDescription(t, sb.Bytes())
+	if cd.Name != "prog" || cd.Description != "Network measurement tool" {
+		t.Fatalf("unexpected description: %+v", cd)
+	}
+	expectPaths := [][]string{
+		{"prog"},
+		{"prog", "list"},
+		{"prog", "run"},
+		{"prog", "run", "websites"},
+	}
+	if paths := commandPaths(cd); !reflect.DeepEqual(paths, expectPaths) {
+		t.Fatalf("expected %v, got %v", expectPaths, paths)
+	}
+	if cd.Positional != (PositionalDescription{Placeholder: "<subcommand> [...]", Min: 1, Max: -1}) {
+		t.Fatalf("unexpected positional: %+v", cd.Positional)
+	}
+	websites := cd.Subcommands[1].Subcommands[0]
+	if websites.Name != "websites" || websites.Positional.Max != 0 {
+		t.Fatalf("unexpected websites description: %+v", websites)
+	}
+	if len(websites.Options) != 1 || websites.Options[0].Long != "force-http-3" {
+		t.Fatalf("unexpected websites options: %+v", websites.Options)
+	}
+	if list := cd.Subcommands[0]; list.Positional.Max != -1 || list.Options[0].Long != "id" {
+		t.Fatalf("unexpected list description: %+v", list)
+	}
+	if run := cd.Subcommands[1]; run.Options[0].Type != "[]string" {
+		t.Fatalf("unexpected run options: %+v", run.Options)
+	}
+}
+
+func TestHelpJSON(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+
+	type testcase struct {
+		name   string
+		argv   []string
+		path   []string
+		expect error
+	}
+
+	testcases := []testcase{{
+		name: "toplevel",
+		argv: []string{"prog", "--help=json"},
+		path: []string{"prog"},
+	}, {
+		name: "subcommand",
+		argv: []string{"prog", "run", "--help=json"},
+		path: []string{"prog", "run"},
+	}, {
+		name:   "invalid format",
+		argv:   []string{"prog", "--help=xml"},
+		expect: &InvalidValueError{},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout := captureStdout(t)
+			captureStderr(t)
+			var options testOptions
+			cli := newTestCLI(&options)
+			selected, err := cli.Getopt(tc.argv)
+			if tc.expect != nil {
+				var ive *InvalidValueError
+				if !errors.As(err, &ive) || ive.Option != "--help" || ive.Value != "xml" {
+					t.Fatalf("unexpected error: %#v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, okay := selected.Options().(*HasPrintedHelp); !okay {
+				t.Fatalf("expected *HasPrintedHelp, got %T", selected.Options())
+			}
+			cd := decodeCommandDescription(t, []byte(stdout()))
+			if !reflect.DeepEqual(cd.Path, tc.path) {
+				t.Fatalf("expected %v, got %v", tc.path, cd.Path)
+			}
+		})
+	}
+}
+
+func TestCommandWriteJSONAfterParsing(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options, AddTimeoutOption())
+	argv := []string{
+		"prog", "-L", "x.log", "--timeout", "5s", "run",
+		"-i", "https://www.example.com/", "websites", "-3",
+	}
+	if _, err := cli.Getopt(argv); err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := cli.WriteJSON(&sb); err != nil {
+		t.Fatal(err)
+	}
+	cd := decodeCommandDescription(t, sb.Bytes())
+
+	defaults := func(options []HelpOption) map[string]string {
+		out := make(map[string]string)
+		for _, o := range options {
+			out[o.Long] = o.Default
+		}
+		return out
+	}
+	expect := map[string]string{"batch": "false", "logfile": "", "timeout": "0s", "verbose": "false"}
+	if got := defaults(cd.Options); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+
+	websites := cd.Subcommands[1].Subcommands[0]
+	expect = map[string]string{"force-http-3": "false", "timeout": "0s"}
+	if got := defaults(websites.Options); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+	var inherited []string
+	for _, o := range websites.InheritedOptions {
+		inherited = append(inherited, o.Long)
+	}
+	if expect := []string{"input", "logfile", "verbose"}; !reflect.DeepEqual(inherited, expect) {
+		t.Fatalf("expected %v, got %v", expect, inherited)
+	}
+	expect = map[string]string{"input": "", "logfile": "", "verbose": "false"}
+	if got := defaults(websites.InheritedOptions); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+	if len(cd.InheritedOptions) != 0 {
+		t.Fatalf("unexpected toplevel inherited options: %+v", cd.InheritedOptions)
+	}
+}
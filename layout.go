@@ -0,0 +1,186 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-wordwrap"
+)
+
+// helpLayout controls the width, the indentation, and the
+// ANSI styles of help messages.
+type helpLayout struct {
+	// width is the total width in columns. Zero means that we should
+	// determine the width from the COLUMNS environment variable or by
+	// querying the terminal, falling back to 80 columns.
+	width int
+
+	// indent is the indentation in columns of the options and
+	// subcommands documentation. Zero means that we should
+	// choose the indentation depending on the width.
+	indent int
+
+	// color controls whether to use ANSI styles.
+	color ColorMode
+
+	// styled indicates whether to use ANSI styles. We only
+	// set this field when resolving the layout.
+	styled bool
+}
+
+const (
+	// defaultHelpWidth is the width we use when we cannot
+	// determine the width of the terminal.
+	defaultHelpWidth = 80
+
+	// minHelpTextWidth is the minimum width of wrapped text.
+	minHelpTextWidth = 20
+)
+
+// resolve returns a copy of the layout where width and indent have been
+// resolved to their actual values for printing on the given writer.
+func (hl *helpLayout) resolve(w io.Writer) *helpLayout {
+	out := &helpLayout{width: hl.width, indent: hl.indent, color: hl.color}
+	out.styled = hl.color.enabled(w)
+	if out.width <= 0 {
+		out.width = helpWidthFromEnvironment(w)
+	}
+	if out.indent <= 0 {
+		// Use a narrower indentation on narrow terminals so that the
+		// documentation remains readable.
+		out.indent = 13
+		if out.width < 60 {
+			out.indent = 6
+		}
+	}
+	return out
+}
+
+// helpWidthFromEnvironment returns the value of the COLUMNS environment
+// variable, if set, or the width of the terminal attached to the writer,
+// if any. Otherwise, this function returns defaultHelpWidth.
+func helpWidthFromEnvironment(w io.Writer) int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if f, okay := w.(*os.File); okay {
+		if columns := terminalWidth(f); columns > 0 {
+			return columns
+		}
+	}
+	return defaultHelpWidth
+}
+
+// docWidth returns the width of the indented documentation. With the default
+// 80 columns width, this function returns 64 columns.
+func (hl *helpLayout) docWidth() int {
+	return maxInt(hl.width-hl.indent-3, minHelpTextWidth)
+}
+
+// descriptionWidth returns the width of a command description. With the
+// default 80 columns width, this function returns 72 columns.
+func (hl *helpLayout) descriptionWidth() int {
+	return maxInt(hl.width-8, minHelpTextWidth)
+}
+
+// printDoc prints the given documentation wrapped and indented.
+func (hl *helpLayout) printDoc(w io.Writer, doc string) {
+	indent := strings.Repeat(" ", hl.indent)
+	for _, line := range strings.Split(wordwrap.WrapString(doc, uint(hl.docWidth())), "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+}
+
+// printDescription prints the given description wrapped but not indented.
+func (hl *helpLayout) printDescription(w io.Writer, doc string) {
+	for _, line := range strings.Split(wordwrap.WrapString(doc, uint(hl.descriptionWidth())), "\n") {
+		fmt.Fprintf(w, "%s\n", line)
+	}
+}
+
+// heading returns the given heading styled in bold, if needed.
+func (hl *helpLayout) heading(s string) string {
+	return stylize(hl.styled, ansiBold, s)
+}
+
+// highlight returns the given option or subcommand name
+// styled with a highlight color, if needed.
+func (hl *helpLayout) highlight(s string) string {
+	return stylize(hl.styled, ansiCyan, s)
+}
+
+// printParagraphs prints the given text, which may contain several paragraphs
+// separated by empty lines, wrapped and indented by the given number of columns.
+func (hl *helpLayout) printParagraphs(w io.Writer, text string, indent int) {
+	prefix := strings.Repeat(" ", indent)
+	width := maxInt(hl.descriptionWidth()-indent, minHelpTextWidth)
+	for idx, paragraph := range splitParagraphs(text) {
+		if idx > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		for _, line := range strings.Split(wordwrap.WrapString(paragraph, uint(width)), "\n") {
+			fmt.Fprintf(w, "%s%s\n", prefix, line)
+		}
+	}
+}
+
+// splitParagraphs splits text into paragraphs separated by empty lines
+// and joins the lines of each paragraph using a single space.
+func splitParagraphs(text string) (out []string) {
+	var current []string
+	for _, line := range strings.Split(text+"\n", "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			current = append(current, line)
+			continue
+		}
+		if len(current) > 0 {
+			out = append(out, strings.Join(current, " "))
+			current = nil
+		}
+	}
+	return
+}
+
+// maxInt returns the maximum between a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// SetHelpWidth is a bit of config that sets the width in columns of the help
+// message printed by PrintUsage. By default, we use the value of the COLUMNS
+// environment variable or we query the terminal, falling back to 80 columns
+// when the output is not a terminal. A zero or negative width restores
+// the default behavior.
+func SetHelpWidth(width int) Config {
+	return &setHelpWidth{width: width}
+}
+
+type setHelpWidth struct {
+	width int
+}
+
+func (c *setHelpWidth) visit(p *parserWrapper) {
+	p.layout.width = c.width
+}
+
+// SetHelpIndent is a bit of config that sets the indentation in columns of
+// the documentation of each option in the help message printed by PrintUsage.
+// By default, we use 13 columns, or fewer columns on narrow terminals. A
+// zero or negative indentation restores the default behavior.
+func SetHelpIndent(indent int) Config {
+	return &setHelpIndent{indent: indent}
+}
+
+type setHelpIndent struct {
+	indent int
+}
+
+func (c *setHelpIndent) visit(p *parserWrapper) {
+	p.layout.indent = c.indent
+}
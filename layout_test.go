@@ -0,0 +1,107 @@
+package getoptx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHelpLayoutResolve(t *testing.T) {
+	type testcase struct {
+		name         string
+		layout       helpLayout
+		columns      string
+		expectWidth  int
+		expectIndent int
+	}
+
+	testcases := []testcase{{
+		name:         "default",
+		columns:      "",
+		expectWidth:  defaultHelpWidth,
+		expectIndent: 13,
+	}, {
+		name:         "COLUMNS",
+		columns:      "120",
+		expectWidth:  120,
+		expectIndent: 13,
+	}, {
+		name:         "invalid COLUMNS",
+		columns:      "xx",
+		expectWidth:  defaultHelpWidth,
+		expectIndent: 13,
+	}, {
+		name:         "explicit width wins over COLUMNS",
+		layout:       helpLayout{width: 100},
+		columns:      "120",
+		expectWidth:  100,
+		expectIndent: 13,
+	}, {
+		name:         "narrow terminal",
+		columns:      "50",
+		expectWidth:  50,
+		expectIndent: 6,
+	}, {
+		name:         "explicit indent",
+		layout:       helpLayout{width: 50, indent: 20},
+		expectWidth:  50,
+		expectIndent: 20,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("COLUMNS", tc.columns)
+			var sb bytes.Buffer
+			resolved := tc.layout.resolve(&sb)
+			if resolved.width != tc.expectWidth || resolved.indent != tc.expectIndent {
+				t.Fatalf("unexpected layout: %+v", resolved)
+			}
+		})
+	}
+}
+
+func TestHelpLayoutWidths(t *testing.T) {
+	layout := &helpLayout{width: 80, indent: 13}
+	if layout.docWidth() != 64 || layout.descriptionWidth() != 72 {
+		t.Fatalf("unexpected widths: %d %d", layout.docWidth(), layout.descriptionWidth())
+	}
+	layout = &helpLayout{width: 10, indent: 6}
+	if layout.docWidth() != minHelpTextWidth || layout.descriptionWidth() != minHelpTextWidth {
+		t.Fatalf("unexpected widths: %d %d", layout.docWidth(), layout.descriptionWidth())
+	}
+}
+
+func TestSplitParagraphs(t *testing.T) {
+	text := "\n  first line\n  second line\n\n\n  third line  \n"
+	expect := []string{"first line second line", "third line"}
+	if got := splitParagraphs(text); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %q, got %q", expect, got)
+	}
+}
+
+func TestSetHelpWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "200")
+	var options struct {
+		Input string `doc:"add URL to measure, which may be repeated many times to measure several URLs in a single run"`
+	}
+	for _, width := range []int{40, 60, 100} {
+		parser, err := NewParser(&options, SetHelpWidth(width), SetProgramName("prog"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sb bytes.Buffer
+		parser.PrintUsage(&sb)
+		lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+		var longest int
+		for _, line := range lines {
+			longest = maxInt(longest, len(line))
+		}
+		if longest > width {
+			t.Fatalf("width %d: line too long:\n%s", width, sb.String())
+		}
+		if width == 40 && len(lines) < 4 {
+			t.Fatalf("width %d: expected wrapped documentation:\n%s", width, sb.String())
+		}
+	}
+}
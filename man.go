@@ -0,0 +1,209 @@
+package getoptx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteManPage writes on w a section-1 roff man page describing the given
+// parser, which must have been created using NewParser or MustNewParser.
+func WriteManPage(w io.Writer, parser Parser) error {
+	pw, okay := parser.(*parserWrapper)
+	if !okay {
+		return errors.New("getoptx: parser not created using NewParser")
+	}
+	return writeManPage(w, pw.newHelpModel(), nil, pw.messages())
+}
+
+// WriteManPages walks the commands tree and writes into dir a section-1 roff
+// man page for each command. The name of each man page is the full command
+// path joined using dashes (e.g., `prog-run-websites.1`). You should call
+// this method on the toplevel command.
+func (p *CommandParser) WriteManPages(dir string) (err error) {
+	p.walkCommands([]*CommandParser{p}, func(chain []*CommandParser) {
+		if err != nil {
+			return
+		}
+		err = writeManPageFile(dir, chain)
+	})
+	return
+}
+
+// writeManPageFile writes the man page of the last command in the chain.
+func writeManPageFile(dir string, chain []*CommandParser) error {
+	p := chain[len(chain)-1]
+	var seeAlso []string
+	if len(chain) > 1 {
+		seeAlso = append(seeAlso, manPageName(chain[:len(chain)-1]))
+	}
+	for _, sc := range p.documentedSubcommands() {
+		subchain := append([]*CommandParser{}, chain...)
+		subchain = append(subchain, sc)
+		seeAlso = append(seeAlso, manPageName(subchain))
+	}
+	filep, err := os.Create(filepath.Join(dir, manPageName(chain)+".1"))
+	if err != nil {
+		return err
+	}
+	if err := writeManPage(filep, p.newHelpModel(chain), seeAlso, chain[0].messages()); err != nil {
+		filep.Close()
+		return err
+	}
+	return filep.Close()
+}
+
+// manPageName returns the name of the man page for the given chain.
+func manPageName(chain []*CommandParser) string {
+	var names []string
+	for _, entry := range chain {
+		names = append(names, entry.name)
+	}
+	return manPageNameFromNames(names)
+}
+
+// manPageNameFromNames returns the name of the man page given the names
+// of the commands from the toplevel command to the current command.
+func manPageNameFromNames(names []string) string {
+	if len(names) > 0 {
+		names = append([]string{filepath.Base(names[0])}, names[1:]...)
+	}
+	return strings.Join(names, "-")
+}
+
+// writeManPage writes on w the man page described by model. The seeAlso
+// argument contains the names of related section-1 man pages and m
+// contains the messages to use for translating the options documentation.
+func writeManPage(w io.Writer, model *HelpModel, seeAlso []string, m *messages) error {
+	var names []string
+	for _, entry := range model.Chain {
+		names = append(names, entry.Name)
+	}
+	name := manPageNameFromNames(names)
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, ".TH \"%s\" 1\n", roffEscape(strings.ToUpper(name)))
+
+	sb.WriteString(".SH NAME\n")
+	sb.WriteString(roffEscape(name))
+	if description := strings.TrimSuffix(model.Description, "."); description != "" {
+		fmt.Fprintf(&sb, " \\- %s", roffEscape(description))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(".SH SYNOPSIS\n")
+	for idx, entry := range model.Chain {
+		if idx == 0 {
+			fmt.Fprintf(&sb, ".B %s\n", roffEscape(filepath.Base(entry.Name)))
+		} else {
+			fmt.Fprintf(&sb, ".B %s\n", roffEscape(entry.Name))
+		}
+		if len(entry.Options) > 0 || !model.IsCommand {
+			sb.WriteString("[\\fIoptions\\fR]\n")
+		}
+		for _, o := range entry.Options {
+			if !o.Required {
+				continue
+			}
+			fmt.Fprintf(&sb, "\\fB\\-\\-%s\\fR", roffEscape(o.Long))
+			if !o.IsFlag {
+				fmt.Fprintf(&sb, " \\fI%s\\fR", roffEscape(o.Metavar))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if model.Positional != "" {
+		fmt.Fprintf(&sb, "\\fI%s\\fR\n", roffEscape(model.Positional))
+	}
+
+	if model.Description != "" {
+		sb.WriteString(".SH DESCRIPTION\n")
+		doc := model.Description
+		if !strings.HasSuffix(doc, ".") {
+			doc += "."
+		}
+		fmt.Fprintf(&sb, "%s\n", roffEscape(doc))
+	}
+	for _, paragraph := range splitParagraphs(model.LongDescription) {
+		fmt.Fprintf(&sb, ".PP\n%s\n", roffEscape(paragraph))
+	}
+
+	var options, inherited []HelpOption
+	if len(model.Chain) > 0 {
+		options = model.Chain[len(model.Chain)-1].Options
+		inherited = inheritedHelpOptions(model.Chain)
+	}
+	if len(options) > 0 || len(inherited) > 0 {
+		sb.WriteString(".SH OPTIONS\n")
+		writeManOptions(&sb, options, m)
+	}
+	if len(inherited) > 0 {
+		sb.WriteString(".SS INHERITED OPTIONS\n")
+		writeManOptions(&sb, inherited, m)
+	}
+
+	if len(model.Examples) > 0 {
+		sb.WriteString(".SH EXAMPLES\n")
+		for _, example := range model.Examples {
+			fmt.Fprintf(&sb, ".TP\n.B %s\n%s\n", roffEscape(example.Command), roffEscape(example.Explanation))
+		}
+	}
+
+	if paragraphs := splitParagraphs(model.Epilog); len(paragraphs) > 0 {
+		sb.WriteString(".SH NOTES\n")
+		for idx, paragraph := range paragraphs {
+			if idx > 0 {
+				sb.WriteString(".PP\n")
+			}
+			fmt.Fprintf(&sb, "%s\n", roffEscape(paragraph))
+		}
+	}
+
+	if len(seeAlso) > 0 {
+		sb.WriteString(".SH SEE ALSO\n")
+		for idx, page := range seeAlso {
+			fmt.Fprintf(&sb, ".BR %s (1)", roffEscape(page))
+			if idx < len(seeAlso)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeManOptions writes on sb the given options as a list of tagged paragraphs.
+func writeManOptions(sb *strings.Builder, options []HelpOption, m *messages) {
+	for _, o := range options {
+		sb.WriteString(".TP\n")
+		if o.Short != "" {
+			fmt.Fprintf(sb, "\\fB\\-%s\\fR, ", roffEscape(o.Short))
+		}
+		fmt.Fprintf(sb, "\\fB\\-\\-%s\\fR", roffEscape(o.Long))
+		if !o.IsFlag {
+			fmt.Fprintf(sb, " \\fI%s\\fR", roffEscape(o.Metavar))
+		}
+		sb.WriteString("\n")
+		doc := optionDoc(o, m)
+		fmt.Fprintf(sb, "%s\n", roffEscape(doc))
+	}
+}
+
+// roffEscape escapes text for inclusion into a roff document.
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\e")
+	text = strings.ReplaceAll(text, "-", "\\-")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = "\\&" + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
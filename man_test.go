@@ -0,0 +1,131 @@
+package getoptx
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteManPage(t *testing.T) {
+	var options struct {
+		Input   string `doc:"add URL to measure" required:"true" metavar:"URL"`
+		Verbose bool   `doc:"run in verbose mode" short:"v"`
+	}
+	parser, err := NewParser(&options, SetProgramName("/usr/bin/prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteManPage(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	expect := `.TH "PROG" 1
+.SH NAME
+prog
+.SH SYNOPSIS
+.B prog
+[\fIoptions\fR]
+\fB\-\-input\fR \fIURL\fR
+\fI[parameters ...]\fR
+.SH OPTIONS
+.TP
+\fB\-\-input\fR \fIURL\fR
+add URL to measure. This option is mandatory.
+.TP
+\fB\-v\fR, \fB\-\-verbose\fR
+run in verbose mode.
+`
+	if sb.String() != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, sb.String())
+	}
+}
+
+func TestWriteManPagesTree(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	dir := t.TempDir()
+	if err := cli.WriteManPages(dir); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expect := []string{"prog-list.1", "prog-run-websites.1", "prog-run.1", "prog.1"} // no help command
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("expected %v, got %v", expect, names)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "prog-run.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		".TH \"PROG\\-RUN\" 1\n.SH NAME\nprog\\-run \\- Runs network measurements\n",
+		".SH SYNOPSIS\n.B prog\n[\\fIoptions\\fR]\n.B run\n[\\fIoptions\\fR]\n\\fI<subcommand> [...]\\fR\n",
+		".SH OPTIONS\n.TP\n\\fB\\-i\\fR, \\fB\\-\\-input\\fR \\fIvalue\\fR\nadd URL to measure.\n",
+		".SH SEE ALSO\n.BR prog (1),\n.BR prog\\-run\\-websites (1)\n",
+	} {
+		if !strings.Contains(string(data), expect) {
+			t.Fatalf("cannot find %q inside:\n%s", expect, string(data))
+		}
+	}
+}
+
+func TestWriteManPagesInheritedOptions(t *testing.T) {
+	withProgramName(t, "prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	dir := t.TempDir()
+	if err := cli.WriteManPages(dir); err != nil {
+		t.Fatal(err)
+	}
+	readPage := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	page := readPage("prog-run-websites.1")
+	expect := `.SH OPTIONS
+.TP
+\fB\-3\fR, \fB\-\-force\-http\-3\fR
+forces using HTTP3.
+.SS INHERITED OPTIONS
+.TP
+\fB\-i\fR, \fB\-\-input\fR \fIvalue\fR
+add URL to measure.
+.TP
+\fB\-L\fR, \fB\-\-logfile\fR \fIvalue\fR
+file where to write logs.
+.TP
+\fB\-v\fR, \fB\-\-verbose\fR
+`
+	if !strings.Contains(page, expect) {
+		t.Fatalf("cannot find %q inside:\n%s", expect, page)
+	}
+	if strings.Contains(page, "batch") {
+		t.Fatalf("unexpected non-persistent option inside:\n%s", page)
+	}
+
+	if page := readPage("prog.1"); strings.Contains(page, "INHERITED OPTIONS") {
+		t.Fatalf("unexpected inherited options inside:\n%s", page)
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	expect := "\\&.leading dot\n\\&'quote, back\\eslash \\- dash"
+	if got := roffEscape(".leading dot\n'quote, back\\slash - dash"); got != expect {
+		t.Fatalf("expected %q, got %q", expect, got)
+	}
+}
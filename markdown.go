@@ -0,0 +1,179 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// WriteMarkdown walks the commands tree and writes on w a single Markdown
+// document describing all the commands reachable from this command. You
+// should call this method on the toplevel command.
+func (p *CommandParser) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	p.walkCommands([]*CommandParser{p}, func(chain []*CommandParser) {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		writeMarkdownCommand(&sb, chain, "##", func(subchain []*CommandParser) string {
+			return "#" + markdownAnchor(docCommandName(subchain))
+		})
+	})
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMarkdownFiles walks the commands tree and writes into dir a Markdown
+// document for each command. The name of each document is the full command
+// path joined using dashes (e.g., `prog-run-websites.md`). You should call
+// this method on the toplevel command.
+func (p *CommandParser) WriteMarkdownFiles(dir string) (err error) {
+	p.walkCommands([]*CommandParser{p}, func(chain []*CommandParser) {
+		if err != nil {
+			return
+		}
+		var sb strings.Builder
+		writeMarkdownCommand(&sb, chain, "#", func(subchain []*CommandParser) string {
+			return manPageName(subchain) + ".md"
+		})
+		err = os.WriteFile(filepath.Join(dir, manPageName(chain)+".md"), []byte(sb.String()), 0644)
+	})
+	return
+}
+
+// walkCommands calls fn for this command and, recursively, for all the
+// documented subcommands (see documentedSubcommands).
+func (p *CommandParser) walkCommands(chain []*CommandParser, fn func(chain []*CommandParser)) {
+	fn(chain)
+	for _, sc := range p.documentedSubcommands() {
+		subchain := append([]*CommandParser{}, chain...)
+		subchain = append(subchain, sc)
+		sc.walkCommands(subchain, fn)
+	}
+}
+
+// documentedSubcommands returns the direct subcommands for which we should
+// generate documentation, i.e., all of them except the internal `help`
+// and the hidden subcommands.
+func (p *CommandParser) documentedSubcommands() (out []*CommandParser) {
+	for _, sc := range p.visibleSubcommands() {
+		if _, okay := sc.options.(*subcommandHelp); !okay {
+			out = append(out, sc)
+		}
+	}
+	return
+}
+
+// docCommandName is like fullcmd but only uses the base name of the
+// toplevel command, which is usually the full path of the program.
+func docCommandName(chain []*CommandParser) string {
+	names := []string{filepath.Base(chain[0].name)}
+	for _, entry := range chain[1:] {
+		names = append(names, entry.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// writeMarkdownCommand writes the Markdown documentation of the last command
+// in the chain using the given heading level. The link function returns the
+// link target for each subcommand of such a command.
+func writeMarkdownCommand(sb *strings.Builder, chain []*CommandParser,
+	heading string, link func(subchain []*CommandParser) string) {
+	p := chain[len(chain)-1]
+	model := p.newHelpModel(chain)
+	m := chain[0].messages()
+	model.Chain[0].Name = filepath.Base(model.Chain[0].Name)
+
+	fmt.Fprintf(sb, "%s %s\n\n", heading, docCommandName(chain))
+	if doc := model.Description; doc != "" {
+		if !strings.HasSuffix(doc, ".") {
+			doc += "."
+		}
+		fmt.Fprintf(sb, "%s\n\n", doc)
+	}
+
+	fmt.Fprintf(sb, "%s# Usage\n\n", heading)
+	fmt.Fprintf(sb, "```\n%s\n```\n", usageLine(model))
+
+	if options := model.Chain[len(model.Chain)-1].Options; len(options) > 0 {
+		fmt.Fprintf(sb, "\n%s# Options\n\n", heading)
+		writeMarkdownOptions(sb, options, m)
+	}
+
+	if inherited := inheritedHelpOptions(model.Chain); len(inherited) > 0 {
+		fmt.Fprintf(sb, "\n%s# Inherited options\n\n", heading)
+		writeMarkdownOptions(sb, inherited, m)
+	}
+
+	if subcommands := p.documentedSubcommands(); len(subcommands) > 0 {
+		fmt.Fprintf(sb, "\n%s# Subcommands\n\n", heading)
+		sb.WriteString("| Command | Description |\n")
+		sb.WriteString("| ------- | ----------- |\n")
+		for _, sc := range subcommands {
+			subchain := append([]*CommandParser{}, chain...)
+			subchain = append(subchain, sc)
+			fmt.Fprintf(sb, "| [`%s`](%s) | %s |\n", docCommandName(subchain),
+				link(subchain), markdownEscapeCell(m.resolve(sc.description)))
+		}
+	}
+
+	if paragraphs := splitParagraphs(model.LongDescription); len(paragraphs) > 0 {
+		fmt.Fprintf(sb, "\n%s# Description\n", heading)
+		for _, paragraph := range paragraphs {
+			fmt.Fprintf(sb, "\n%s\n", paragraph)
+		}
+	}
+
+	if len(model.Examples) > 0 {
+		fmt.Fprintf(sb, "\n%s# Examples\n", heading)
+		for _, example := range model.Examples {
+			fmt.Fprintf(sb, "\n%s\n\n```\n%s\n```\n", example.Explanation, example.Command)
+		}
+	}
+
+	for _, paragraph := range splitParagraphs(model.Epilog) {
+		fmt.Fprintf(sb, "\n%s\n", paragraph)
+	}
+}
+
+// writeMarkdownOptions writes on sb the given options as a table.
+func writeMarkdownOptions(sb *strings.Builder, options []HelpOption, m *messages) {
+	sb.WriteString("| Option | Description |\n")
+	sb.WriteString("| ------ | ----------- |\n")
+	for _, o := range options {
+		var names []string
+		if o.Short != "" {
+			names = append(names, "`-"+o.Short+"`")
+		}
+		long := "--" + o.Long
+		if !o.IsFlag {
+			long += " " + o.Metavar
+		}
+		names = append(names, "`"+long+"`")
+		doc := optionDoc(o, m)
+		fmt.Fprintf(sb, "| %s | %s |\n", strings.Join(names, ", "), markdownEscapeCell(doc))
+	}
+}
+
+// markdownAnchor returns the anchor that GitHub generates for a heading.
+func markdownAnchor(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			sb.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// markdownEscapeCell escapes text for inclusion into a Markdown table cell.
+func markdownEscapeCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.ReplaceAll(text, "\n", " ")
+}
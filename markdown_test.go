@@ -0,0 +1,108 @@
+package getoptx
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	var sb bytes.Buffer
+	if err := cli.WriteMarkdown(&sb); err != nil {
+		t.Fatal(err)
+	}
+	expect := "## prog\n\nNetwork measurement tool.\n\n" +
+		"### Usage\n\n```\nprog [options] <subcommand> [...]\n```\n\n" +
+		"### Options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-b`, `--batch` | emit JSON messages. |\n" +
+		"| `-L`, `--logfile value` | file where to write logs. |\n" +
+		"| `-v`, `--verbose` | run in verbose mode, which prints a lot of debug messages that you typically do not want to see unless you are debugging. |\n\n" +
+		"### Subcommands\n\n| Command | Description |\n| ------- | ----------- |\n" +
+		"| [`prog list`](#prog-list) | Lists network measurements |\n" +
+		"| [`prog run`](#prog-run) | Runs network measurements |\n\n" +
+		"## prog list\n\nLists network measurements.\n\n" +
+		"### Usage\n\n```\nprog [options] list [options] <argument> [<argument> ...]\n```\n\n" +
+		"### Options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `--id value` | ID of the result to show. |\n\n" +
+		"### Inherited options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-L`, `--logfile value` | file where to write logs. |\n" +
+		"| `-v`, `--verbose` | run in verbose mode, which prints a lot of debug messages that you typically do not want to see unless you are debugging. |\n\n" +
+		"## prog run\n\nRuns network measurements.\n\n" +
+		"### Usage\n\n```\nprog [options] run [options] <subcommand> [...]\n```\n\n" +
+		"### Options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-i`, `--input value` | add URL to measure. |\n\n" +
+		"### Inherited options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-L`, `--logfile value` | file where to write logs. |\n" +
+		"| `-v`, `--verbose` | run in verbose mode, which prints a lot of debug messages that you typically do not want to see unless you are debugging. |\n\n" +
+		"### Subcommands\n\n| Command | Description |\n| ------- | ----------- |\n" +
+		"| [`prog run websites`](#prog-run-websites) | Tests websites for censorship |\n\n" +
+		"## prog run websites\n\nTests websites for censorship.\n\n" +
+		"### Usage\n\n```\nprog [options] run [options] websites [options]\n```\n\n" +
+		"### Options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-3`, `--force-http-3` | forces using HTTP3. |\n\n" +
+		"### Inherited options\n\n| Option | Description |\n| ------ | ----------- |\n" +
+		"| `-i`, `--input value` | add URL to measure. |\n" +
+		"| `-L`, `--logfile value` | file where to write logs. |\n" +
+		"| `-v`, `--verbose` | run in verbose mode, which prints a lot of debug messages that you typically do not want to see unless you are debugging. |\n"
+	if sb.String() != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, sb.String())
+	}
+}
+
+func TestWriteMarkdownFiles(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	dir := t.TempDir()
+	if err := cli.WriteMarkdownFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "prog-run.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		"# prog run\n",
+		"## Usage\n",
+		"| [`prog run websites`](prog-run-websites.md) | Tests websites for censorship |\n",
+	} {
+		if !strings.Contains(string(data), expect) {
+			t.Fatalf("cannot find %q inside:\n%s", expect, string(data))
+		}
+	}
+	for _, name := range []string{"prog.md", "prog-list.md", "prog-run-websites.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMarkdownAnchor(t *testing.T) {
+	type testcase struct {
+		heading string
+		expect  string
+	}
+
+	testcases := []testcase{
+		{heading: "prog run websites", expect: "prog-run-websites"},
+		{heading: "Prog Run_Fast", expect: "prog-run_fast"},
+		{heading: "prog (v1.0)", expect: "prog-v10"},
+	}
+
+	for _, tc := range testcases {
+		if got := markdownAnchor(tc.heading); got != tc.expect {
+			t.Errorf("markdownAnchor(%q): expected %q, got %q", tc.heading, tc.expect, got)
+		}
+	}
+}
+
+func TestMarkdownEscapeCell(t *testing.T) {
+	if got := markdownEscapeCell("a|b\nc"); got != `a\|b c` {
+		t.Fatalf("unexpected escaped cell: %q", got)
+	}
+}
@@ -0,0 +1,38 @@
+package getoptx
+
+import (
+	"reflect"
+	"time"
+)
+
+// defaultMetavar returns the placeholder for the value of an option with the
+// given type that we use when the option has no `metavar:"..."` tag.
+func defaultMetavar(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return "string..."
+		}
+	}
+	return "value"
+}
+
+// optionSynopsis returns the synopsis of the given option (e.g.,
+// "--input URL" or "--verbose") as used in the usage string.
+func optionSynopsis(o HelpOption) string {
+	if o.IsFlag {
+		return "--" + o.Long
+	}
+	return "--" + o.Long + " " + o.Metavar
+}
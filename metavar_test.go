@@ -0,0 +1,65 @@
+package getoptx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultMetavar(t *testing.T) {
+	type testcase struct {
+		value  interface{}
+		expect string
+	}
+
+	testcases := []testcase{
+		{value: int(0), expect: "int"},
+		{value: int64(0), expect: "int"},
+		{value: uint16(0), expect: "uint"},
+		{value: float64(0), expect: "float"},
+		{value: "", expect: "string"},
+		{value: []string{}, expect: "string..."},
+		{value: time.Duration(0), expect: "duration"},
+		{value: []int{}, expect: "value"},
+		{value: Counter(0), expect: "int"},
+	}
+
+	for _, tc := range testcases {
+		if got := defaultMetavar(reflect.TypeOf(tc.value)); got != tc.expect {
+			t.Errorf("defaultMetavar(%T): expected %q, got %q", tc.value, tc.expect, got)
+		}
+	}
+}
+
+func TestMetavar(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	var options struct {
+		Count   int           `doc:"number of measurements"`
+		Input   []string      `doc:"add URL to measure" metavar:"URL"`
+		Output  string        `doc:"the output file" short:"o" metavar:"FILE" required:"true"`
+		Timeout time.Duration `doc:"the timeout"`
+		Verbose bool          `doc:"run in verbose mode" metavar:"IGNORED"`
+	}
+	parser, err := NewParser(&options, SetProgramName("prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	parser.PrintUsage(&sb)
+	expect := []string{
+		"Options:",
+		"--count int",
+		"--input URL",
+		"-o, --output FILE",
+		"--timeout duration",
+		"--verbose",
+	}
+	if got := usageHeadingsAndOptions(sb.String()); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %q, got %q", expect, got)
+	}
+	if !strings.Contains(sb.String(), "Usage: prog [options] --output FILE [parameters ...]") {
+		t.Fatalf("unexpected usage:\n%s", sb.String())
+	}
+}
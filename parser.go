@@ -11,14 +11,15 @@ import (
 	"unicode/utf8"
 
 	"github.com/iancoleman/strcase"
-	"github.com/mitchellh/go-wordwrap"
 	"github.com/pborman/getopt/v2"
 )
 
 // Parser is a command line parser.
 type Parser interface {
 	// Getopt parses the command line options in args, which in the
-	// common case should be just os.Args.
+	// common case should be just os.Args. Parsing errors are reported
+	// using typed errors such as *UnknownOptionError, *MissingRequiredError,
+	// and *InvalidValueError, which you can inspect using errors.As.
 	Getopt(args []string) error
 
 	// MustGetopt is like Getopt but prints usage and exits on error.
@@ -45,7 +46,8 @@ type Config interface {
 func MustNewParser(flags interface{}, configs ...Config) Parser {
 	parser, err := NewParser(flags, configs...)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		m := messagesFromConfigs(configs)
+		fmt.Fprintf(os.Stderr, "%s %s\n", m.get(MsgError), m.describeError(err))
 		os.Exit(1)
 	}
 	return parser
@@ -69,6 +71,25 @@ func MustNewParser(flags interface{}, configs ...Config) Parser {
 //
 // The `required:"true"` tag indicates that an option is required.
 //
+// The `persistent:"true"` tag indicates that the option of a command is
+// persistent, i.e., the user can also specify it after the name of any
+// of the command's subcommands (e.g., `prog run websites --verbose` as
+// well as `prog --verbose run websites`). In both cases, we write the value
+// into the options structure of the command declaring the option. This tag
+// has no effect on parsers. We check whether a required persistent option
+// is present after parsing the leaf command, hence the user may specify it
+// either before or after the subcommand name.
+//
+// The `metavar:"URL"` tag sets the placeholder of the option's value used
+// by the help message (e.g., `--input URL`). By default, we derive such a
+// placeholder from the field type (e.g., "int", "duration", "string...").
+//
+// The `section:"Network"` tag groups an option under the "Network options"
+// heading of the help message. We list the options without a section first,
+// followed by each section in the order in which it first appears inside
+// the structure. Use SortOptionsByDeclaration to list the options of each
+// section in declaration order rather than alphabetically.
+//
 // For example:
 //
 //     type CLI struct {
@@ -79,7 +100,7 @@ func MustNewParser(flags interface{}, configs ...Config) Parser {
 //
 // becomes:
 //
-//     program [-h,--help] --input value [-v,--verbose]
+//     program [-h,--help] --input string [-v,--verbose]
 //
 // Note that, by default, this parser does not treat `-h` or `--help`
 // specially; you'll need to implement actions for them.
@@ -109,24 +130,31 @@ func newParserWrapper(flags interface{}, configs ...Config) (*parserWrapper, err
 	// the structure type and its value.
 	value := reflect.ValueOf(flags)
 	if value.Kind() != reflect.Ptr {
-		return nil, errors.New("expected a pointer")
+		return nil, &catalogError{key: MsgExpectedPointer}
 	}
 	pointee := value.Elem()
 	if pointee.Kind() != reflect.Struct {
-		return nil, errors.New("expected a pointer to struct")
+		return nil, &catalogError{key: MsgExpectedPointerToStruct}
 	}
 	pointeeType := pointee.Type()
 
 	// 2. we process each field inside the struct.
 	docs := make(map[string]string)
 	required := make(map[string]bool)
+	completers := make(map[string]Completer)
+	defaults := make(map[string]string)
+	types := make(map[string]string)
+	order := make(map[string]int)
+	sections := make(map[string]string)
+	metavars := make(map[string]string)
+	persistent := make(map[string]bool)
 	for idx := 0; idx < pointeeType.NumField(); idx++ {
 
 		// 3. obtain the field value, a pointer to the value, the
 		// field type, and the associated tags.
 		fieldValue := pointee.Field(idx)
 		if !fieldValue.CanAddr() {
-			return nil, errors.New("cannot obtain the address of a field")
+			return nil, &catalogError{key: MsgUnaddressableField}
 		}
 		fieldValuePtr := fieldValue.Addr()
 		fieldType := pointeeType.Field(idx)
@@ -139,14 +167,14 @@ func newParserWrapper(flags interface{}, configs ...Config) (*parserWrapper, err
 			continue
 		}
 		if docstring == "" {
-			return nil, errors.New("there is a field without documentation")
+			return nil, &catalogError{key: MsgUndocumentedField}
 		}
 
 		// 5. a field may have a short associated option.
 		short := rune(0)
 		if shortName := tag.Get("short"); shortName != "" {
 			if len(shortName) != 1 {
-				return nil, errors.New("the short tag's value must contain a single-byte string")
+				return nil, &catalogError{key: MsgInvalidShortTag}
 			}
 			short, _ = utf8.DecodeRune([]byte(shortName))
 		}
@@ -154,10 +182,12 @@ func newParserWrapper(flags interface{}, configs ...Config) (*parserWrapper, err
 		// 6. the long option name is the kebab-case of the field name.
 		name := strcase.ToKebab(fieldType.Name)
 		docs[name] = docstring
+		order[name] = len(order)
+		sections[name] = tag.Get("section")
 
 		// 7. add this option to pborman's parser.
 		if !fieldValuePtr.CanInterface() {
-			return nil, errors.New("a field inside the structure is private")
+			return nil, &catalogError{key: MsgPrivateField}
 		}
 		opt := parser.FlagLong(fieldValuePtr.Interface(), name, short, docstring)
 		switch fieldValuePtr.Interface().(type) {
@@ -166,23 +196,48 @@ func newParserWrapper(flags interface{}, configs ...Config) (*parserWrapper, err
 		default:
 			// nothing
 		}
+		if completer, okay := fieldValuePtr.Interface().(Completer); okay {
+			completers[name] = completer
+		}
+		defaults[name] = opt.String()
+		types[name] = fieldType.Type.String()
+		if !opt.IsFlag() {
+			metavars[name] = tag.Get("metavar")
+			if metavars[name] == "" {
+				metavars[name] = defaultMetavar(fieldType.Type)
+			}
+		}
 
-		// 8. an option could be marked as required.
+		// 8. an option could be marked as required. We check for required
+		// options ourselves to emit a MissingRequiredError.
 		if tag.Get("required") == "true" {
 			required[name] = true
-			opt.Mandatory()
+		}
+
+		// 9. an option of a command could be marked as persistent.
+		if tag.Get("persistent") == "true" {
+			persistent[name] = true
 		}
 	}
 
-	// 9. wrap pborman's parser.
+	// 10. wrap pborman's parser.
 	pw := &parserWrapper{
-		set:      parser,
-		docs:     docs,
-		pac:      newPositionalArgumentsChecker(),
-		required: required,
+		set:        parser,
+		completers: completers,
+		defaults:   defaults,
+		docs:       docs,
+		inherited:  make(map[string]int),
+		layout:     &helpLayout{},
+		metavars:   metavars,
+		order:      order,
+		pac:        newPositionalArgumentsChecker(),
+		persistent: persistent,
+		required:   required,
+		sections:   sections,
+		types:      types,
 	}
 
-	// 10. apply config bits
+	// 11. apply config bits
 	for _, config := range configs {
 		config.visit(pw)
 	}
@@ -194,14 +249,74 @@ type parserWrapper struct {
 	// Set is the underlying cmdline parser.
 	set *getopt.Set
 
+	// catalog is the optional message catalog.
+	catalog Catalog
+
+	// completers contains the options implementing Completer.
+	completers map[string]Completer
+
+	// defaults contains the options values at construction time.
+	defaults map[string]string
+
 	// docs contains the documentation.
 	docs map[string]string
 
+	// inherited maps the persistent options of the ancestor commands
+	// registered with this parser to the index inside the chain of
+	// the command declaring them.
+	inherited map[string]int
+
+	// layout controls the help layout.
+	layout *helpLayout
+
+	// metavars contains the placeholder of each option's value.
+	metavars map[string]string
+
+	// order contains the declaration index of each option.
+	order map[string]int
+
+	// renderer is the optional custom help renderer.
+	renderer HelpRenderer
+
 	// pac is the positional arguments checker.
 	pac *positionalArgumentsChecker
 
+	// persistent tracks the persistent options.
+	persistent map[string]bool
+
 	// required tracks the required options.
 	required map[string]bool
+
+	// sections contains the section of each option.
+	sections map[string]string
+
+	// types contains the Go type of each option.
+	types map[string]string
+
+	// offset is the index inside the original argv of the
+	// first argument passed to Getopt.
+	offset int
+
+	// indexes maps the index of each argument passed to pborman's
+	// parser to its index inside the argv passed to Getopt, which
+	// differs from the identity when we permute arguments.
+	indexes []int
+
+	// prefixes indicates whether to accept unambiguous
+	// prefixes of long options.
+	prefixes bool
+
+	// permute indicates whether to permute arguments.
+	permute bool
+
+	// deferRequired indicates that Getopt should not check whether the
+	// required options are present, because CommandParser checks them
+	// for the whole chain of commands after handling -h/--help.
+	deferRequired bool
+
+	// declarationOrder indicates whether to list options in
+	// declaration order rather than alphabetically.
+	declarationOrder bool
 }
 
 // numOptions counts the number of registered options.
@@ -210,8 +325,9 @@ func (p *parserWrapper) numOptions() int {
 }
 
 // maybeAddHelpFlags attempts to register -h/--help. If the user
-// has already configured -h/--help we'll just do nothing.
-func (p *parserWrapper) maybeAddHelpFlags(help *bool) bool {
+// has already configured -h/--help we'll just do nothing. We also
+// store into format the requested help format (see helpValue).
+func (p *parserWrapper) maybeAddHelpFlags(help *bool, format *string) bool {
 	var found bool
 	p.set.VisitAll(func(o getopt.Option) {
 		found = found || o.ShortName() == "h" || o.LongName() == "help"
@@ -219,8 +335,11 @@ func (p *parserWrapper) maybeAddHelpFlags(help *bool) bool {
 	if found {
 		return false
 	}
-	p.set.FlagLong(help, "help", 'h', "Prints this help message")
-	p.docs["help"] = "Prints this help message"
+	doc := "msgid:" + MsgHelpOption
+	p.set.FlagLong(&helpValue{enabled: help, format: format}, "help", 'h', doc).SetFlag()
+	p.docs["help"] = doc
+	p.defaults["help"] = "false"
+	p.types["help"] = "bool"
 	return true
 }
 
@@ -242,6 +361,9 @@ type positionalArgumentsChecker struct {
 
 	// maxArgs is the maximum acceptable number of positional arguments.
 	maxArgs int
+
+	// validate is the optional user-defined validator.
+	validate func(args []string) error
 }
 
 // newPositionalArgumentsChecker creates a new checker for positional arguments.
@@ -264,12 +386,54 @@ var (
 
 // Getopt implements Parser.Getopt.
 func (p *parserWrapper) Getopt(args []string) error {
+	p.indexes = nil
+	if p.shouldPermute() {
+		args, p.indexes = p.permuteArgs(args)
+	}
+	if p.prefixes {
+		expanded, err := p.expandPrefixes(args)
+		if err != nil {
+			return err
+		}
+		args = expanded
+	}
 	if err := p.set.Getopt(args, nil); err != nil {
+		// pborman's parser leaves the offending argument at the
+		// beginning of the remaining arguments.
+		index := len(args) - len(p.set.Args())
+		var arg string
+		if index >= 0 && index < len(args) {
+			arg = args[index]
+		}
+		err = newParseError(err, p.set.Program(), p.argIndex(index), arg)
+		if uoe, okay := err.(*UnknownOptionError); okay {
+			uoe.Suggestions = p.suggestOptions(uoe.Option)
+		}
 		return err
 	}
+	if !p.deferRequired {
+		if err := p.checkRequired(); err != nil {
+			return err
+		}
+	}
 	return p.pac.check(p)
 }
 
+// checkRequired ensures that all the required options have been set.
+func (p *parserWrapper) checkRequired() (err error) {
+	p.set.VisitAll(func(o getopt.Option) {
+		if err == nil && p.required[o.LongName()] && !o.Seen() {
+			err = &MissingRequiredError{
+				Command: p.set.Program(),
+				Index:   -1,
+				Option:  "--" + o.LongName(),
+				Value:   "",
+			}
+		}
+	})
+	return
+}
+
 func (pac *positionalArgumentsChecker) check(p Parser) error {
 	count := p.NArgs()
 	if count < pac.minArgs {
@@ -278,13 +442,33 @@ func (pac *positionalArgumentsChecker) check(p Parser) error {
 	if count > pac.maxArgs {
 		return ErrTooManyPositionalArguments
 	}
+	if pac.validate != nil {
+		return pac.validate(p.Args())
+	}
 	return nil
 }
 
+// suggestOptions returns the long options similar to the given option. We
+// do not provide suggestions for short options because they are too short.
+func (p *parserWrapper) suggestOptions(option string) []string {
+	if !strings.HasPrefix(option, "--") {
+		return nil
+	}
+	var candidates []string
+	p.set.VisitAll(func(o getopt.Option) {
+		if o.LongName() != "" {
+			candidates = append(candidates, "--"+o.LongName())
+		}
+	})
+	return suggest(option, candidates)
+}
+
 // MustGetopt implements Parser.MustGetopt.
 func (p *parserWrapper) MustGetopt(args []string) {
 	if err := p.Getopt(args); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		m := p.messages()
+		fmt.Fprintf(os.Stderr, "%s %s\n", p.errorPrefix(os.Stderr, m.get(MsgError)), m.describeError(err))
+		printSuggestions(os.Stderr, err, m)
 		p.PrintUsage(os.Stderr)
 		os.Exit(1)
 	}
@@ -292,43 +476,81 @@ func (p *parserWrapper) MustGetopt(args []string) {
 
 // PrintUsage implements Parser.PrintUsage.
 func (p *parserWrapper) PrintUsage(w io.Writer) {
-	p.printBriefUsage(w)
-	fmt.Fprintf(w, "\n")
-	fmt.Fprintf(w, "Options:\n\n")
-	p.printOptions(w)
+	p.helpRenderer().RenderHelp(w, p.newHelpModel())
 }
 
-func (p *parserWrapper) printOptions(w io.Writer) {
-	p.set.VisitAll(func(o getopt.Option) {
-		if o.ShortName() != "" {
-			fmt.Fprintf(w, "  -%s, --%s", o.ShortName(), o.LongName())
-		} else {
-			fmt.Fprintf(w, "      --%s", o.LongName())
-		}
-		if !o.IsFlag() {
-			fmt.Fprintf(w, " value")
-		}
-		fmt.Fprintf(w, "\n")
-		doc := p.docs[o.LongName()]
-		if !strings.HasSuffix(doc, ".") {
-			doc += "."
-		}
-		if p.required[o.LongName()] {
-			doc += " This option is mandatory."
-		}
-		for _, line := range strings.Split(wordwrap.WrapString(doc, 64), "\n") {
-			fmt.Fprintf(w, "             %s\n", line)
-		}
-		fmt.Fprintf(w, "\n")
-	})
+// helpRenderer returns the configured HelpRenderer or the default one.
+func (p *parserWrapper) helpRenderer() HelpRenderer {
+	if p.renderer != nil {
+		return p.renderer
+	}
+	return &defaultHelpRenderer{layout: p.layout, messages: p.messages()}
+}
+
+// messages returns the messages for the configured catalog.
+func (p *parserWrapper) messages() *messages {
+	return newMessages(p.catalog)
 }
 
-func (p *parserWrapper) printBriefUsage(w io.Writer) {
+// newHelpModel creates the HelpModel describing this parser.
+func (p *parserWrapper) newHelpModel() *HelpModel {
 	var parameters string
 	if p.pac.maxArgs >= 1 {
 		parameters = p.set.Parameters()
 	}
-	fmt.Fprintf(w, "\nUsage: %s [options] %s\n", p.set.Program(), parameters)
+	return &HelpModel{
+		IsCommand: false,
+		Chain: []HelpCommand{{
+			Name:    p.set.Program(),
+			Options: p.helpOptions(),
+		}},
+		Description: "",
+		Positional:  parameters,
+		Subcommands: nil,
+	}
+}
+
+// helpOptions returns the description of the registered options except
+// for the persistent options inherited from the ancestor commands, which
+// we list among the options of the command that declares them.
+func (p *parserWrapper) helpOptions() []HelpOption {
+	return p.describeOptions(false)
+}
+
+// describeOptions returns the description of either the options inherited
+// from the ancestor commands, if inherited is true, or the other options.
+func (p *parserWrapper) describeOptions(inherited bool) (out []HelpOption) {
+	m := p.messages()
+	p.set.VisitAll(func(o getopt.Option) {
+		if _, found := p.inherited[o.LongName()]; found != inherited {
+			return
+		}
+		out = append(out, HelpOption{
+			Long:     o.LongName(),
+			Short:    o.ShortName(),
+			Doc:      m.resolve(p.docs[o.LongName()]),
+			Required: p.required[o.LongName()],
+			IsFlag:   o.IsFlag(),
+			Type:     p.types[o.LongName()],
+			Default:  p.defaults[o.LongName()],
+			Section:  p.sections[o.LongName()],
+			Metavar:  p.metavars[o.LongName()],
+
+			Persistent: p.persistent[o.LongName()] || inherited,
+		})
+	})
+	p.sortOptions(out)
+	return
+}
+
+// setDefaults overrides the default values of the options we have
+// already registered with the given ones (see optionsDefaults).
+func (p *parserWrapper) setDefaults(defaults map[string]string) {
+	for name, value := range defaults {
+		if _, found := p.defaults[name]; found {
+			p.defaults[name] = value
+		}
+	}
 }
 
 // SetProgramName sets the program name printed in the usage string.
@@ -407,3 +629,21 @@ func JustOnePositionalArgument() Config {
 		maxArgs: 1,
 	}
 }
+
+// ValidatePositionalArguments is a bit of config that causes Parse to
+// call the given function to validate the positional arguments. This
+// function runs after we've checked the number of positional arguments
+// and its error, if any, is returned to the caller of Getopt. To localize
+// the error message we print, the error's message may refer to a catalog
+// message using the `msgid:` prefix (see Catalog).
+func ValidatePositionalArguments(fn func(args []string) error) Config {
+	return &validatePositionalArguments{fn: fn}
+}
+
+type validatePositionalArguments struct {
+	fn func(args []string) error
+}
+
+func (c *validatePositionalArguments) visit(p *parserWrapper) {
+	p.pac.validate = c.fn
+}
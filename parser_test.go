@@ -0,0 +1,105 @@
+package getoptx
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// errNotAURL is the error returned by validateURLs.
+var errNotAURL = errors.New("not a valid URL")
+
+// validateURLs is a positional arguments validator for testing.
+func validateURLs(args []string) error {
+	for _, arg := range args {
+		if u, err := url.Parse(arg); err != nil || u.Scheme == "" {
+			return fmt.Errorf("%w: %s", errNotAURL, arg)
+		}
+	}
+	return nil
+}
+
+func TestValidatePositionalArguments(t *testing.T) {
+	type testcase struct {
+		name    string
+		configs []Config // configs to use in addition to the validator
+		argv    []string
+		expect  error
+		calls   int
+	}
+
+	testcases := []testcase{{
+		name:  "valid arguments",
+		argv:  []string{"prog", "https://example.com", "http://example.org"},
+		calls: 1,
+	}, {
+		name:   "invalid arguments",
+		argv:   []string{"prog", "https://example.com", "example.org"},
+		expect: errNotAURL,
+		calls:  1,
+	}, {
+		name:    "we check the number of arguments first",
+		configs: []Config{AtLeastOnePositionalArgument()},
+		argv:    []string{"prog"},
+		expect:  ErrTooFewPositionalArguments,
+		calls:   0,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options struct {
+				Verbose bool `doc:"run in verbose mode"`
+			}
+			var calls int
+			configs := append([]Config{}, tc.configs...)
+			configs = append(configs, ValidatePositionalArguments(func(args []string) error {
+				calls++
+				return validateURLs(args)
+			}))
+			parser, err := NewParser(&options, configs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := parser.Getopt(tc.argv); !errors.Is(err, tc.expect) {
+				t.Fatalf("expected %v, got %v", tc.expect, err)
+			}
+			if calls != tc.calls {
+				t.Fatalf("expected %d calls, got %d", tc.calls, calls)
+			}
+		})
+	}
+}
+
+func TestValidatePositionalArgumentsWithCommands(t *testing.T) {
+	withProgramName(t, "prog")
+	var options struct {
+		Global struct{}
+		Get    struct{}
+	}
+	var got []string
+	cli := Command(
+		"Test program",
+		&options.Global,
+		LeafSubcommand("get", "Fetches URLs", &options.Get,
+			AtLeastOnePositionalArgument(),
+			ValidatePositionalArguments(func(args []string) error {
+				got = append([]string{}, args...)
+				return validateURLs(args)
+			}),
+		),
+	)
+	captureStderr(t)
+
+	if _, err := cli.Getopt([]string{"prog", "get", "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"https://example.com"}) {
+		t.Fatalf("unexpected arguments passed to the validator: %v", got)
+	}
+
+	if _, err := cli.Getopt([]string{"prog", "get", "example.com"}); !errors.Is(err, errNotAURL) {
+		t.Fatalf("expected %v, got %v", errNotAURL, err)
+	}
+}
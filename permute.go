@@ -0,0 +1,108 @@
+package getoptx
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// PermuteArguments is a bit of config that causes Parse to permute the
+// command line arguments like GNU getopt_long does, such that the user can
+// specify options after positional arguments (e.g., `prog 42 --verbose`
+// like `prog --verbose 42`). As usual, `--` terminates the options, hence
+// all the arguments following it are positional arguments. Setting the
+// POSIXLY_CORRECT environment variable disables permutation, so Parse
+// stops processing options at the first positional argument.
+//
+// When using commands, you can pass this config either to LeafSubcommand,
+// to enable permutation for a specific leaf command, or to Configure, to
+// enable permutation for all the leaf commands. We never permute the
+// arguments of commands with subcommands, since we need to stop processing
+// options at the subcommand name to pass the remaining arguments to it.
+func PermuteArguments() Config {
+	return &permuteArguments{}
+}
+
+type permuteArguments struct{}
+
+func (c *permuteArguments) visit(p *parserWrapper) {
+	p.permute = true
+}
+
+// permuteArgs returns a copy of args where we have moved the options and
+// their values before the positional arguments, which we separate from the
+// options using `--`. We also return the index inside args of each returned
+// argument, which we use to report the position of errors.
+func (p *parserWrapper) permuteArgs(args []string) ([]string, []int) {
+	if len(args) < 1 {
+		return args, nil
+	}
+	var longNames []string
+	p.set.VisitAll(func(o getopt.Option) {
+		if o.LongName() != "" {
+			longNames = append(longNames, o.LongName())
+		}
+	})
+	options, optionsIndexes := []string{args[0]}, []int{0}
+	var positionals []string
+	var positionalsIndexes []int
+	for idx := 1; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "--" {
+			for idx++; idx < len(args); idx++ {
+				positionals = append(positionals, args[idx])
+				positionalsIndexes = append(positionalsIndexes, idx)
+			}
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			positionals = append(positionals, arg)
+			positionalsIndexes = append(positionalsIndexes, idx)
+			continue
+		}
+		options = append(options, arg)
+		optionsIndexes = append(optionsIndexes, idx)
+		option := arg
+		if p.prefixes && strings.HasPrefix(arg, "--") {
+			if matches := p.matchLongOption(arg[2:], longNames); len(matches) == 1 {
+				option = "--" + matches[0]
+			}
+		}
+		if p.nextArgumentOwner(option) == "" {
+			continue
+		}
+		if idx+1 >= len(args) {
+			// The option is missing its value. Keep the option as the last
+			// argument, such that it cannot swallow the `--` we would otherwise
+			// add, and let pborman's parser report the missing parameter.
+			return options, optionsIndexes
+		}
+		idx++ // the option's value
+		options = append(options, args[idx])
+		optionsIndexes = append(optionsIndexes, idx)
+	}
+	if len(positionals) <= 0 {
+		return options, optionsIndexes
+	}
+	out := append(options, "--")
+	out = append(out, positionals...)
+	indexes := append(optionsIndexes, positionalsIndexes[0]) // `--` is never wrong
+	indexes = append(indexes, positionalsIndexes...)
+	return out, indexes
+}
+
+// shouldPermute returns whether we should permute the arguments.
+func (p *parserWrapper) shouldPermute() bool {
+	_, posixlyCorrect := os.LookupEnv("POSIXLY_CORRECT")
+	return p.permute && !posixlyCorrect
+}
+
+// argIndex maps the index of an argument passed to pborman's parser to
+// the index of the same argument inside the original argv.
+func (p *parserWrapper) argIndex(idx int) int {
+	if idx >= 0 && idx < len(p.indexes) {
+		idx = p.indexes[idx]
+	}
+	return p.offset + idx
+}
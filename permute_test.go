@@ -0,0 +1,178 @@
+package getoptx
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPermuteArguments(t *testing.T) {
+	type options struct {
+		ID      int    `doc:"the ID" short:"I"`
+		Name    string `doc:"the name"`
+		Verbose bool   `doc:"verbose mode" short:"v"`
+	}
+
+	type testcase struct {
+		name        string
+		argv        []string
+		posix       bool
+		wantOptions options
+		wantArgs    []string
+		wantErr     bool
+	}
+
+	testcases := []testcase{{
+		name:        "options after positionals",
+		argv:        []string{"prog", "a", "--verbose", "b", "--name", "x", "-I", "7"},
+		wantOptions: options{ID: 7, Name: "x", Verbose: true},
+		wantArgs:    []string{"a", "b"},
+	}, {
+		name:        "dash dash terminates options",
+		argv:        []string{"prog", "a", "--", "--verbose", "-"},
+		wantOptions: options{},
+		wantArgs:    []string{"a", "--verbose", "-"},
+	}, {
+		name:        "single dash is a positional",
+		argv:        []string{"prog", "-", "-v"},
+		wantOptions: options{Verbose: true},
+		wantArgs:    []string{"-"},
+	}, {
+		name:        "POSIXLY_CORRECT disables permutation",
+		argv:        []string{"prog", "a", "--verbose"},
+		posix:       true,
+		wantOptions: options{},
+		wantArgs:    []string{"a", "--verbose"},
+	}, {
+		name:    "missing string value after positional",
+		argv:    []string{"prog", "a", "--name"},
+		wantErr: true,
+	}, {
+		name:    "missing int value after positional",
+		argv:    []string{"prog", "a", "--id"},
+		wantErr: true,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.posix {
+				t.Setenv("POSIXLY_CORRECT", "1")
+			}
+			var opts options
+			parser, err := NewParser(&opts, PermuteArguments())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = parser.Getopt(tc.argv)
+			if tc.wantErr {
+				var ive *InvalidValueError
+				if !errors.As(err, &ive) {
+					t.Fatalf("expected *InvalidValueError, got %T %v", err, err)
+				}
+				if !strings.Contains(ive.Error(), "missing parameter") {
+					t.Fatalf("unexpected error: %s", ive.Error())
+				}
+				if ive.Index != len(tc.argv)-1 {
+					t.Fatalf("unexpected index: %d", ive.Index)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts != tc.wantOptions {
+				t.Fatalf("expected %+v, got %+v", tc.wantOptions, opts)
+			}
+			if !reflect.DeepEqual(parser.Args(), tc.wantArgs) {
+				t.Fatalf("expected %v, got %v", tc.wantArgs, parser.Args())
+			}
+		})
+	}
+}
+
+func TestPermuteArgumentsErrorIndex(t *testing.T) {
+	type options struct {
+		Verbose bool `doc:"verbose mode"`
+	}
+	var opts options
+	parser, err := NewParser(&opts, PermuteArguments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.Getopt([]string{"prog", "a", "b", "--nonexistent", "c"})
+	var uoe *UnknownOptionError
+	if !errors.As(err, &uoe) {
+		t.Fatalf("expected *UnknownOptionError, got %T %v", err, err)
+	}
+	if uoe.Index != 3 {
+		t.Fatalf("expected index 3, got %d", uoe.Index)
+	}
+}
+
+func TestPermuteArgumentsCommands(t *testing.T) {
+	type globalOptions struct {
+		Token string `doc:"the token"`
+	}
+	type leafOptions struct {
+		ID   int    `doc:"the ID"`
+		Name string `doc:"the name"`
+	}
+
+	newCLI := func(global *globalOptions, leaf *leafOptions) *CommandParser {
+		cli := Command("Test program", global, LeafSubcommand("run", "Runs", leaf))
+		cli.Configure(PermuteArguments())
+		return cli
+	}
+
+	t.Run("options after positionals", func(t *testing.T) {
+		var (
+			global globalOptions
+			leaf   leafOptions
+		)
+		sc, err := newCLI(&global, &leaf).Getopt(
+			[]string{"prog", "--token", "x", "run", "a", "--name", "n", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if global.Token != "x" || leaf.Name != "n" {
+			t.Fatalf("unexpected options: %+v %+v", global, leaf)
+		}
+		if !reflect.DeepEqual(sc.Args(), []string{"a", "b"}) {
+			t.Fatalf("unexpected args: %v", sc.Args())
+		}
+	})
+
+	t.Run("we never permute commands with subcommands", func(t *testing.T) {
+		var (
+			global globalOptions
+			leaf   leafOptions
+		)
+		_, err := newCLI(&global, &leaf).Getopt([]string{"prog", "run", "--token", "x"})
+		var uoe *UnknownOptionError
+		if !errors.As(err, &uoe) || uoe.Index != 2 {
+			t.Fatalf("expected *UnknownOptionError at index 2, got %T %v", err, err)
+		}
+	})
+
+	for _, option := range []string{"--name", "--id"} {
+		t.Run("missing value for "+option, func(t *testing.T) {
+			var (
+				global globalOptions
+				leaf   leafOptions
+			)
+			argv := []string{"prog", "--token", "x", "run", "a", option}
+			_, err := newCLI(&global, &leaf).Getopt(argv)
+			var ive *InvalidValueError
+			if !errors.As(err, &ive) {
+				t.Fatalf("expected *InvalidValueError, got %T %v", err, err)
+			}
+			if ive.Error() != "missing parameter for "+option {
+				t.Fatalf("unexpected error: %s", ive.Error())
+			}
+			if ive.Index != 5 {
+				t.Fatalf("expected index 5, got %d", ive.Index)
+			}
+		})
+	}
+}
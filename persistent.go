@@ -0,0 +1,115 @@
+package getoptx
+
+import (
+	"github.com/pborman/getopt/v2"
+)
+
+// addPersistentOptions registers with parser the persistent options (i.e.,
+// the options tagged with `persistent:"true"`) of the ancestors of the last
+// command in the chain, starting from the innermost ancestor, such that the
+// user can also specify them after the subcommand name (e.g., `prog run
+// websites --verbose` in addition to `prog --verbose run websites`).
+func (p *CommandParser) addPersistentOptions(parser *parserWrapper, chain []*CommandParser) error {
+	for idx := len(chain) - 2; idx >= 0; idx-- {
+		ancestor, err := newParserWrapper(chain[idx].options)
+		if err != nil {
+			return err
+		}
+		ancestor.setDefaults(chain[idx].defaults)
+		parser.addPersistentOptions(ancestor, idx)
+	}
+	return nil
+}
+
+// addPersistentOptions registers the persistent options of the ancestor
+// parser, which write into the ancestor's options structure. We skip the
+// options whose long name clashes with an option we have already registered,
+// since the options of the innermost command win, and we only register the
+// long name of the options whose short name clashes (e.g., we register
+// `--logfile` but not `-L` when the leaf command uses `-L` for `--level`). The index
+// argument is the index inside the chain of the ancestor command. We do not
+// mark the inherited options as required, since CommandParser.checkRequired
+// checks them after parsing the leaf command. We also do not list the
+// inherited options in the help message, since we already list them among
+// the options of the command that declares them.
+func (p *parserWrapper) addPersistentOptions(ancestor *parserWrapper, index int) {
+	ancestor.set.VisitAll(func(o getopt.Option) {
+		name := o.LongName()
+		if !ancestor.persistent[name] || p.hasOption(name, "") {
+			return
+		}
+		var short rune
+		if o.ShortName() != "" && !p.hasOption("", o.ShortName()) {
+			short = rune(o.ShortName()[0])
+		}
+		opt := p.set.FlagLong(o.Value(), name, short, ancestor.docs[name])
+		if o.IsFlag() {
+			opt.SetFlag()
+		}
+		if completer, found := ancestor.completers[name]; found {
+			p.completers[name] = completer
+		}
+		p.docs[name] = ancestor.docs[name]
+		p.defaults[name] = ancestor.defaults[name]
+		p.types[name] = ancestor.types[name]
+		p.metavars[name] = ancestor.metavars[name]
+		p.sections[name] = ancestor.sections[name]
+		p.order[name] = len(p.order)
+		p.inherited[name] = index
+	})
+}
+
+// seenInherited returns whether the user has specified the option with the
+// given long name inherited from the command at the given chain index.
+func (p *parserWrapper) seenInherited(name string, index int) bool {
+	if declaring, found := p.inherited[name]; !found || declaring != index {
+		return false
+	}
+	var seen bool
+	p.set.VisitAll(func(o getopt.Option) {
+		seen = seen || (o.LongName() == name && o.Seen())
+	})
+	return seen
+}
+
+// inheritedOptions returns the description of the persistent options
+// inherited from the ancestor commands that we accept at this level.
+func (p *parserWrapper) inheritedOptions() []HelpOption {
+	return p.describeOptions(true)
+}
+
+// hasOption returns whether we have already registered an option with
+// the given long name or with the given short name. An empty name does
+// not match any option.
+func (p *parserWrapper) hasOption(long, short string) bool {
+	var found bool
+	p.set.VisitAll(func(o getopt.Option) {
+		found = found || (long != "" && o.LongName() == long) || (short != "" && o.ShortName() == short)
+	})
+	return found
+}
+
+// inheritedHelpOptions returns the persistent options of the ancestors of the
+// last command in the chain, which the user can also specify after the name
+// of such a command. Like the parser, we start from the innermost ancestor,
+// skip the options whose long name clashes with the ones we have already
+// collected, and drop the short name when only such a name clashes.
+func inheritedHelpOptions(chain []HelpCommand) (out []HelpOption) {
+	seen := make(map[string]bool)
+	for _, o := range chain[len(chain)-1].Options {
+		seen["--"+o.Long], seen["-"+o.Short] = true, true
+	}
+	for idx := len(chain) - 2; idx >= 0; idx-- {
+		for _, o := range chain[idx].Options {
+			if !o.Persistent || seen["--"+o.Long] {
+				continue
+			}
+			if o.Short != "" && seen["-"+o.Short] {
+				o.Short = ""
+			}
+			seen["--"+o.Long], seen["-"+o.Short] = true, true
+			out = append(out, o)
+		}
+	}
+	return
+}
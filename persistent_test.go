@@ -0,0 +1,278 @@
+package getoptx
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPersistentOptions(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect testOptions
+	}
+
+	var verboseAndLogfile testOptions
+	verboseAndLogfile.Global.Verbose = true
+	verboseAndLogfile.Global.Logfile = "x.log"
+
+	var combinedShort testOptions
+	combinedShort.Global.Verbose = true
+	combinedShort.Websites.ForceHTTP3 = true
+
+	var intermediate testOptions
+	intermediate.Global.Logfile = "x.log"
+	intermediate.Run.Input = []string{"https://www.example.com/"}
+
+	testcases := []testcase{{
+		name:   "before the subcommand name",
+		argv:   []string{"prog", "-v", "--logfile", "x.log", "run", "websites"},
+		expect: verboseAndLogfile,
+	}, {
+		name:   "after the subcommand name",
+		argv:   []string{"prog", "run", "--verbose", "websites", "--logfile=x.log"},
+		expect: verboseAndLogfile,
+	}, {
+		name:   "combined with the leaf short options",
+		argv:   []string{"prog", "run", "websites", "-v3"},
+		expect: combinedShort,
+	}, {
+		name:   "inherited from an intermediate command",
+		argv:   []string{"prog", "run", "websites", "--input", "https://www.example.com/", "-L", "x.log"},
+		expect: intermediate,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options testOptions
+			sc, err := newTestCLI(&options).Getopt(tc.argv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.Options() != &options.Websites {
+				t.Fatalf("unexpected selected command: %+v", sc)
+			}
+			if !reflect.DeepEqual(options, tc.expect) {
+				t.Fatalf("expected %+v, got %+v", tc.expect, options)
+			}
+		})
+	}
+
+	t.Run("non persistent options are not inherited", func(t *testing.T) {
+		captureStderr(t)
+		var options testOptions
+		_, err := newTestCLI(&options).Getopt([]string{"prog", "run", "websites", "--batch"})
+		var unknown *UnknownOptionError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected *UnknownOptionError, got %T", err)
+		}
+	})
+
+	t.Run("the innermost command wins", func(t *testing.T) {
+		var options struct {
+			Global struct {
+				Verbose bool   `doc:"run in verbose mode" short:"v" persistent:"true"`
+				Logfile string `doc:"file where to write logs" short:"L" persistent:"true"`
+			}
+			Run struct {
+				Verbose bool `doc:"show the progress"`
+				Level   int  `doc:"the log level" short:"L"`
+			}
+		}
+		cli := Command(
+			"Network measurement tool",
+			&options.Global,
+			LeafSubcommand("run", "Runs network measurements", &options.Run),
+		)
+		if _, err := cli.Getopt([]string{"prog", "run", "--verbose", "-L", "3"}); err != nil {
+			t.Fatal(err)
+		}
+		if options.Global.Verbose || options.Global.Logfile != "" {
+			t.Fatalf("unexpected toplevel options: %+v", options.Global)
+		}
+		if !options.Run.Verbose || options.Run.Level != 3 {
+			t.Fatalf("unexpected run options: %+v", options.Run)
+		}
+	})
+
+	t.Run("partial name clash", func(t *testing.T) {
+		var options struct {
+			Global struct {
+				Logfile string `doc:"file where to write logs" short:"L" persistent:"true"`
+			}
+			Run struct {
+				Level int `doc:"the log level" short:"L"`
+			}
+		}
+		cli := Command(
+			"Network measurement tool",
+			&options.Global,
+			LeafSubcommand("run", "Runs network measurements", &options.Run),
+		)
+		if _, err := cli.Getopt([]string{"prog", "run", "-L", "3", "--logfile", "x.log"}); err != nil {
+			t.Fatal(err)
+		}
+		if options.Global.Logfile != "x.log" || options.Run.Level != 3 {
+			t.Fatalf("unexpected options: %+v", options)
+		}
+
+		var sb bytes.Buffer
+		if err := cli.WriteJSON(&sb); err != nil {
+			t.Fatal(err)
+		}
+		inherited := decodeCommandDescription(t, sb.Bytes()).Subcommands[0].InheritedOptions
+		if len(inherited) != 1 || inherited[0].Long != "logfile" || inherited[0].Short != "" {
+			t.Fatalf("unexpected inherited options: %+v", inherited)
+		}
+	})
+}
+
+func TestPersistentOptionsCompletion(t *testing.T) {
+	withProgramName(t, "prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+
+	t.Run("bash", func(t *testing.T) {
+		var sb bytes.Buffer
+		if err := cli.WriteBashCompletion(&sb); err != nil {
+			t.Fatal(err)
+		}
+		script := sb.String()
+		for _, expect := range []string{
+			"'prog run --input'|",
+			"'prog run websites --logfile'",
+		} {
+			if !strings.Contains(script, expect) {
+				t.Fatalf("cannot find %q inside:\n%s", expect, script)
+			}
+		}
+		if strings.Contains(script, "'prog run websites --batch'") {
+			t.Fatalf("unexpected non-persistent option inside:\n%s", script)
+		}
+	})
+
+	t.Run("fish", func(t *testing.T) {
+		var sb bytes.Buffer
+		if err := cli.WriteFishCompletion(&sb); err != nil {
+			t.Fatal(err)
+		}
+		script := sb.String()
+		if !strings.Contains(script, `\'prog run websites\'' -s 'L' -l 'logfile' -r`) {
+			t.Fatalf("missing persistent option inside:\n%s", script)
+		}
+		if strings.Contains(script, `\'prog run websites\'' -s 'b'`) {
+			t.Fatalf("unexpected non-persistent option inside:\n%s", script)
+		}
+	})
+
+	t.Run("zsh", func(t *testing.T) {
+		var sb bytes.Buffer
+		if err := cli.WriteZshCompletion(&sb); err != nil {
+			t.Fatal(err)
+		}
+		script := sb.String()
+		start := strings.Index(script, "_prog_run_websites() {")
+		if start < 0 {
+			t.Fatalf("missing function inside:\n%s", script)
+		}
+		function := script[start:]
+		if end := strings.Index(function, "\n}\n"); end >= 0 {
+			function = function[:end]
+		}
+		if !strings.Contains(function, "{'-L+','--logfile='}'[file where to write logs]:value: '") {
+			t.Fatalf("missing persistent option inside:\n%s", function)
+		}
+		if strings.Contains(function, "--batch") {
+			t.Fatalf("unexpected non-persistent option inside:\n%s", function)
+		}
+	})
+}
+
+func TestPersistentRequiredOptions(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type options struct {
+		Global struct {
+			Token string `doc:"the authentication token" persistent:"true" required:"true"`
+		}
+		Run struct {
+			Input string `doc:"add URL to measure"`
+		}
+		Websites struct {
+			Token string `doc:"shadows the global token"`
+		}
+		URLGetter struct {
+			Timeout int `doc:"the timeout"`
+		}
+	}
+
+	type testcase struct {
+		name   string
+		argv   []string
+		token  string
+		expect string
+	}
+
+	testcases := []testcase{{
+		name:  "before the subcommand name",
+		argv:  []string{"prog", "--token", "x", "run", "urlgetter"},
+		token: "x",
+	}, {
+		name:  "after the subcommand name",
+		argv:  []string{"prog", "run", "--token", "x", "urlgetter"},
+		token: "x",
+	}, {
+		name:  "after the leaf subcommand name",
+		argv:  []string{"prog", "run", "urlgetter", "--token", "x"},
+		token: "x",
+	}, {
+		name:   "missing",
+		argv:   []string{"prog", "run", "urlgetter"},
+		expect: "--token",
+	}, {
+		name:   "shadowed by a leaf option",
+		argv:   []string{"prog", "run", "websites", "--token", "x"},
+		expect: "--token",
+	}, {
+		name:  "help does not require it",
+		argv:  []string{"prog", "run", "--help"},
+		token: "",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts options
+			cli := Command(
+				"Network measurement tool",
+				&opts.Global,
+				Subcommand(
+					"run", "Runs network measurements", &opts.Run,
+					LeafSubcommand("websites", "Tests websites", &opts.Websites),
+					LeafSubcommand("urlgetter", "Fetches URLs", &opts.URLGetter),
+				),
+			)
+			_, err := cli.Getopt(tc.argv)
+			if tc.expect != "" {
+				var mre *MissingRequiredError
+				if !errors.As(err, &mre) {
+					t.Fatalf("expected MissingRequiredError, got %v", err)
+				}
+				if mre.Option != tc.expect || mre.Index != -1 {
+					t.Fatalf("unexpected error: %+v", mre)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Global.Token != tc.token {
+				t.Fatalf("expected %q, got %q", tc.token, opts.Global.Token)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package getoptx
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// AllowPrefixMatching is a bit of config that causes Parse to accept
+// unambiguous prefixes of long options (e.g., `--verb` for `--verbose`)
+// like GNU getopt_long does. An ambiguous prefix causes Parse to fail
+// with an *AmbiguousOptionError listing the possible candidates. As with
+// pborman's parser, a single-letter long option matching a short option
+// (e.g., `--v` for `-v`) selects such a short option rather than a prefix.
+func AllowPrefixMatching() Config {
+	return &allowPrefixMatching{}
+}
+
+type allowPrefixMatching struct{}
+
+func (c *allowPrefixMatching) visit(p *parserWrapper) {
+	p.prefixes = true
+}
+
+// matchPrefix returns the candidates matching name. If name is equal to a
+// candidate, we only return such a candidate. Otherwise, we return all the
+// candidates having name as their prefix, sorted alphabetically.
+func matchPrefix(name string, candidates []string) []string {
+	var out []string
+	for _, candidate := range candidates {
+		if candidate == name {
+			return []string{candidate}
+		}
+		if strings.HasPrefix(candidate, name) {
+			out = append(out, candidate)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// matchLongOption is like matchPrefix but returns no candidates when
+// name is not a long option and is the name of a short option, since
+// pborman's parser treats `--v` like `-v` in such a case.
+func (p *parserWrapper) matchLongOption(name string, longNames []string) []string {
+	matches := matchPrefix(name, longNames)
+	if len(matches) == 1 && matches[0] == name {
+		return matches
+	}
+	var short bool
+	p.set.VisitAll(func(o getopt.Option) {
+		short = short || (len(name) == 1 && o.ShortName() == name)
+	})
+	if short {
+		return nil
+	}
+	return matches
+}
+
+// expandPrefixes returns a copy of args where every unambiguous prefix of a
+// long option has been replaced by the full long option name. We only process
+// the arguments that pborman's parser would consider as options, i.e., we stop
+// at the first non-option argument or at `--`, and we skip option values.
+func (p *parserWrapper) expandPrefixes(args []string) ([]string, error) {
+	var longNames []string
+	p.set.VisitAll(func(o getopt.Option) {
+		if o.LongName() != "" {
+			longNames = append(longNames, o.LongName())
+		}
+	})
+	out := append([]string{}, args...)
+	for idx := 1; idx < len(out); idx++ {
+		arg := out[idx]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value := arg[2:], ""
+			if eq := strings.Index(name, "="); eq >= 0 {
+				name, value = name[:eq], name[eq:]
+			}
+			matches := p.matchLongOption(name, longNames)
+			switch {
+			case len(matches) > 1:
+				return nil, &AmbiguousOptionError{
+					Command:    p.set.Program(),
+					Index:      p.argIndex(idx),
+					Option:     "--" + name,
+					Value:      arg,
+					Candidates: prependDashes(matches),
+				}
+			case len(matches) == 1:
+				out[idx] = "--" + matches[0] + value
+			}
+		}
+		if p.nextArgumentOwner(out[idx]) != "" {
+			idx++ // skip the option's value
+		}
+	}
+	return out, nil
+}
+
+// nextArgumentOwner returns the option (e.g., "--id" or "-I") that consumes
+// the argument following arg as its value, or an empty string if arg does not
+// need the following argument. For example, given `-vI`, where -v is a flag
+// and -I takes a value, this function returns "-I".
+func (p *parserWrapper) nextArgumentOwner(arg string) (owner string) {
+	flags := make(map[string]bool)
+	p.set.VisitAll(func(o getopt.Option) {
+		if o.LongName() != "" {
+			flags["--"+o.LongName()] = o.IsFlag()
+		}
+		if o.ShortName() != "" {
+			flags["-"+o.ShortName()] = o.IsFlag()
+		}
+	})
+	if strings.HasPrefix(arg, "--") {
+		isFlag, found := flags[arg]
+		if !found && len(arg) == 3 {
+			isFlag, found = flags[arg[1:]] // pborman treats `--v` like `-v`
+		}
+		if found && !isFlag {
+			return arg
+		}
+		return ""
+	}
+	for pos, c := range arg[1:] {
+		option := "-" + string(c)
+		isFlag, found := flags[option]
+		if !found {
+			return ""
+		}
+		if !isFlag {
+			if pos+len(string(c)) >= len(arg)-1 {
+				return option
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// prependDashes prepends `--` to each long option name.
+func prependDashes(names []string) []string {
+	var out []string
+	for _, name := range names {
+		out = append(out, "--"+name)
+	}
+	return out
+}
@@ -0,0 +1,244 @@
+package getoptx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// prefixTestOptions contains the options we use for testing prefixes.
+type prefixTestOptions struct {
+	ID      int    `doc:"the result ID" short:"I"`
+	Input   string `doc:"add URL to measure"`
+	Verbose bool   `doc:"run in verbose mode" short:"v"`
+	Version bool   `doc:"print the version"`
+}
+
+func TestAllowPrefixMatching(t *testing.T) {
+	type testcase struct {
+		name       string
+		argv       []string
+		expect     prefixTestOptions
+		args       []string
+		candidates []string
+		index      int
+	}
+
+	testcases := []testcase{{
+		name:   "unambiguous prefix",
+		argv:   []string{"prog", "--inp", "x", "--verb"},
+		expect: prefixTestOptions{Input: "x", Verbose: true},
+	}, {
+		name:   "unambiguous prefix with value",
+		argv:   []string{"prog", "--in=x", "a"},
+		expect: prefixTestOptions{Input: "x"},
+		args:   []string{"a"},
+	}, {
+		name:   "option values are not expanded",
+		argv:   []string{"prog", "--input", "--verb"},
+		expect: prefixTestOptions{Input: "--verb"},
+	}, {
+		name:   "arguments after -- are not expanded",
+		argv:   []string{"prog", "--", "--verb"},
+		expect: prefixTestOptions{},
+		args:   []string{"--verb"},
+	}, {
+		name:   "single letter short alias wins over prefix",
+		argv:   []string{"prog", "--v"},
+		expect: prefixTestOptions{Verbose: true},
+	}, {
+		name:   "single letter short alias taking a value",
+		argv:   []string{"prog", "--I", "12", "--inp", "x"},
+		expect: prefixTestOptions{ID: 12, Input: "x"},
+	}, {
+		name:       "ambiguous prefix",
+		argv:       []string{"prog", "--inp", "x", "--ver"},
+		candidates: []string{"--verbose", "--version"},
+		index:      3,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options prefixTestOptions
+			parser, err := NewParser(&options, AllowPrefixMatching())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = parser.Getopt(tc.argv)
+			if tc.candidates != nil {
+				var aoe *AmbiguousOptionError
+				if !errors.As(err, &aoe) {
+					t.Fatalf("expected AmbiguousOptionError, got %v", err)
+				}
+				if !reflect.DeepEqual(aoe.Candidates, tc.candidates) || aoe.Index != tc.index {
+					t.Fatalf("unexpected error: %+v", aoe)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options != tc.expect {
+				t.Fatalf("expected %+v, got %+v", tc.expect, options)
+			}
+			if parser.NArgs() != len(tc.args) || (len(tc.args) > 0 && !reflect.DeepEqual(parser.Args(), tc.args)) {
+				t.Fatalf("expected %v, got %v", tc.args, parser.Args())
+			}
+		})
+	}
+}
+
+func TestPrefixMatchingDisabled(t *testing.T) {
+	var options prefixTestOptions
+	parser, err := NewParser(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uoe *UnknownOptionError
+	if err := parser.Getopt([]string{"prog", "--verb"}); !errors.As(err, &uoe) {
+		t.Fatalf("expected UnknownOptionError, got %v", err)
+	}
+}
+
+func TestCommandPrefixMatching(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type options struct {
+		Global   prefixTestOptions
+		Run      struct{}
+		Websites struct {
+			ForceHTTP3 bool `doc:"forces using HTTP3" short:"3"`
+		}
+		WhatsApp        struct{}
+		WebConnectivity struct{}
+		Rm              struct{}
+	}
+
+	type testcase struct {
+		name       string
+		argv       []string
+		leaf       func(opts *options) interface{}
+		candidates []string
+		unknown    bool
+	}
+
+	testcases := []testcase{{
+		name: "unambiguous prefixes",
+		argv: []string{"prog", "--verb", "ru", "web", "--force"},
+		leaf: func(opts *options) interface{} { return &opts.Websites },
+	}, {
+		name: "exact match wins",
+		argv: []string{"prog", "rm"},
+		leaf: func(opts *options) interface{} { return &opts.Rm },
+	}, {
+		name:       "ambiguous subcommand",
+		argv:       []string{"prog", "run", "w"},
+		candidates: []string{"websites", "whatsapp"},
+	}, {
+		name: "hidden subcommand exact match",
+		argv: []string{"prog", "run", "webconnectivity"},
+		leaf: func(opts *options) interface{} { return &opts.WebConnectivity },
+	}, {
+		name:    "hidden subcommand prefix",
+		argv:    []string{"prog", "run", "webc"},
+		unknown: true,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			captureStderr(t)
+			var opts options
+			cli := Command(
+				"Network measurement tool",
+				&opts.Global,
+				Subcommand(
+					"run", "Runs network measurements", &opts.Run,
+					LeafSubcommand("websites", "Tests websites", &opts.Websites),
+					LeafSubcommand("whatsapp", "Tests WhatsApp", &opts.WhatsApp),
+					LeafSubcommand("webconnectivity", "Tests web connectivity",
+						&opts.WebConnectivity).SetHidden(true),
+				),
+				LeafSubcommand("rm", "Removes measurements", &opts.Rm),
+			).SetPrefixMatching(true)
+			selected, err := cli.Getopt(tc.argv)
+			if tc.unknown {
+				var use *UnknownSubcommandError
+				if !errors.As(err, &use) {
+					t.Fatalf("expected UnknownSubcommandError, got %v", err)
+				}
+				return
+			}
+			if tc.candidates != nil {
+				var ase *AmbiguousSubcommandError
+				if !errors.As(err, &ase) {
+					t.Fatalf("expected AmbiguousSubcommandError, got %v", err)
+				}
+				if !reflect.DeepEqual(ase.Candidates, tc.candidates) || ase.Index != 2 {
+					t.Fatalf("unexpected error: %+v", ase)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selected.Options() != tc.leaf(&opts) {
+				t.Fatalf("unexpected selected command: %+v", selected)
+			}
+		})
+	}
+}
+
+func TestNextArgumentOwner(t *testing.T) {
+	var options struct {
+		ID      int    `doc:"the ID" short:"I"`
+		Name    string `doc:"the name"`
+		Verbose bool   `doc:"verbose mode" short:"v"`
+	}
+	parser, err := newParserWrapper(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testcase struct {
+		arg    string
+		expect string
+	}
+
+	testcases := []testcase{
+		{arg: "--name", expect: "--name"},
+		{arg: "--name=x", expect: ""},
+		{arg: "--verbose", expect: ""},
+		{arg: "--nonexistent", expect: ""},
+		{arg: "-I", expect: "-I"},
+		{arg: "-I12", expect: ""},
+		{arg: "-vI", expect: "-I"},
+		{arg: "-Iv", expect: ""},
+		{arg: "-vx", expect: ""},
+		{arg: "--I", expect: "--I"},
+		{arg: "--v", expect: ""},
+	}
+
+	for _, tc := range testcases {
+		if got := parser.nextArgumentOwner(tc.arg); got != tc.expect {
+			t.Errorf("nextArgumentOwner(%q): expected %q, got %q", tc.arg, tc.expect, got)
+		}
+	}
+}
+
+func TestPrefixMatchingWithPermutation(t *testing.T) {
+	var options prefixTestOptions
+	parser, err := NewParser(&options, AllowPrefixMatching(), PermuteArguments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parser.Getopt([]string{"prog", "a", "--I", "12", "--inp", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := prefixTestOptions{ID: 12, Input: "b"}
+	if options != expect {
+		t.Fatalf("expected %+v, got %+v", expect, options)
+	}
+	if !reflect.DeepEqual(parser.Args(), []string{"a", "c"}) {
+		t.Fatalf("unexpected positional arguments: %v", parser.Args())
+	}
+}
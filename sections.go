@@ -0,0 +1,73 @@
+package getoptx
+
+import (
+	"math"
+	"sort"
+)
+
+// SortOptionsByDeclaration is a bit of config that causes the help message
+// to list options in the order in which they appear inside the structure
+// rather than alphabetically. This setting does not change the way in which
+// we group options using the `section:"..."` tag: in both cases, we list
+// sections in the order in which they first appear inside the structure.
+func SortOptionsByDeclaration() Config {
+	return &sortOptionsByDeclaration{}
+}
+
+type sortOptionsByDeclaration struct{}
+
+func (c *sortOptionsByDeclaration) visit(p *parserWrapper) {
+	p.declarationOrder = true
+}
+
+// sortOptions sorts the given options, which must be sorted alphabetically,
+// such that the options without section come first, followed by the options
+// of each section in declaration order. Within each group, we keep the
+// alphabetical order unless we've been configured to use the declaration
+// order. The options we did not create from the structure (e.g., -h/--help)
+// come last in declaration order.
+func (p *parserWrapper) sortOptions(options []HelpOption) {
+	declared := func(name string) int {
+		if idx, found := p.order[name]; found {
+			return idx
+		}
+		return math.MaxInt
+	}
+	if p.declarationOrder {
+		sort.SliceStable(options, func(i, j int) bool {
+			return declared(options[i].Long) < declared(options[j].Long)
+		})
+	}
+	sections := make(map[string]int)
+	for _, o := range options {
+		if idx, found := sections[o.Section]; !found || declared(o.Long) < idx {
+			sections[o.Section] = declared(o.Long)
+		}
+	}
+	sections[""] = -1
+	sort.SliceStable(options, func(i, j int) bool {
+		return sections[options[i].Section] < sections[options[j].Section]
+	})
+}
+
+// optionSection is a group of options sharing the same section.
+type optionSection struct {
+	// name is the section name or an empty string for
+	// the options that do not belong to any section.
+	name string
+
+	// options contains the options in this section.
+	options []HelpOption
+}
+
+// optionSections splits options, already sorted using sortOptions,
+// into consecutive groups of options sharing the same section.
+func optionSections(options []HelpOption) (out []optionSection) {
+	for _, o := range options {
+		if len(out) <= 0 || out[len(out)-1].name != o.Section {
+			out = append(out, optionSection{name: o.Section})
+		}
+		out[len(out)-1].options = append(out[len(out)-1].options, o)
+	}
+	return
+}
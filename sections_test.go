@@ -0,0 +1,133 @@
+package getoptx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sectionsTestOptions contains the options we use for testing sections.
+type sectionsTestOptions struct {
+	Verbose bool   `doc:"run in verbose mode" short:"v"`
+	Timeout int    `doc:"the timeout" section:"Network"`
+	Batch   bool   `doc:"emit JSON messages"`
+	Proxy   string `doc:"the proxy URL" section:"Network"`
+	Output  string `doc:"the output file" section:"Output"`
+	Address string `doc:"the address" section:"Network"`
+}
+
+// usageHeadingsAndOptions returns the headings and the options
+// inside the given usage string, ignoring the documentation.
+func usageHeadingsAndOptions(usage string) (out []string) {
+	for _, line := range strings.Split(usage, "\n") {
+		switch {
+		case strings.HasSuffix(line, ":"):
+			out = append(out, line)
+		case strings.HasPrefix(strings.TrimSpace(line), "-"):
+			out = append(out, strings.TrimSpace(line))
+		}
+	}
+	return
+}
+
+func TestOptionSections(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+
+	type testcase struct {
+		name    string
+		configs []Config
+		expect  []string
+	}
+
+	testcases := []testcase{{
+		name: "alphabetical order",
+		expect: []string{
+			"Options:",
+			"--batch",
+			"-v, --verbose",
+			"Network options:",
+			"--address string",
+			"--proxy string",
+			"--timeout int",
+			"Output options:",
+			"--output string",
+		},
+	}, {
+		name:    "declaration order",
+		configs: []Config{SortOptionsByDeclaration()},
+		expect: []string{
+			"Options:",
+			"-v, --verbose",
+			"--batch",
+			"Network options:",
+			"--timeout int",
+			"--proxy string",
+			"--address string",
+			"Output options:",
+			"--output string",
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var options sectionsTestOptions
+			configs := append([]Config{SetProgramName("prog")}, tc.configs...)
+			parser, err := NewParser(&options, configs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sb bytes.Buffer
+			parser.PrintUsage(&sb)
+			if got := usageHeadingsAndOptions(sb.String()); !reflect.DeepEqual(got, tc.expect) {
+				t.Fatalf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestOptionSectionsWithCommands(t *testing.T) {
+	withProgramName(t, "prog")
+	t.Setenv("COLUMNS", "80")
+	stdout := captureStdout(t)
+	var options sectionsTestOptions
+	cli := Command("Test program", &options)
+	if _, err := cli.Getopt([]string{"prog", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"Options for prog:",
+		"--batch",
+		"-v, --verbose",
+		"Network options for prog:",
+		"--address string",
+		"--proxy string",
+		"--timeout int",
+		"Output options for prog:",
+		"--output string",
+		"Subcommands:",
+	}
+	if got := usageHeadingsAndOptions(stdout()); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %q, got %q", expect, got)
+	}
+}
+
+func TestOptionSectionsJSON(t *testing.T) {
+	var options sectionsTestOptions
+	parser, err := NewParser(&options, SetProgramName("prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteJSON(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	cd := decodeCommandDescription(t, sb.Bytes())
+	sections := make(map[string]string)
+	for _, o := range cd.Options {
+		sections[o.Long] = o.Section
+	}
+	if sections["proxy"] != "Network" || sections["output"] != "Output" || sections["batch"] != "" {
+		t.Fatalf("unexpected sections: %v", sections)
+	}
+}
@@ -0,0 +1,138 @@
+package getoptx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"time"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// CancelOnSignals is a bit of config that causes CommandParser.Execute to
+// cancel the context passed to the selected command's RunFunc when the program
+// receives SIGINT or SIGTERM. Receiving a second signal forces the program
+// to exit immediately. You should pass this config to Configure. This
+// config has no effect on parsers.
+func CancelOnSignals() Config {
+	return &cancelOnSignals{}
+}
+
+type cancelOnSignals struct{}
+
+func (c *cancelOnSignals) visit(p *parserWrapper) {
+	// nothing
+}
+
+// AddTimeoutOption is a bit of config that adds the `--timeout duration`
+// option to each command. When the user specifies a positive timeout (e.g.,
+// `--timeout 30s`), CommandParser.Execute cancels the context passed to the
+// selected command's RunFunc once the timeout expires. When the user specifies
+// the timeout for several commands in the chain (e.g., `prog --timeout 1m
+// run --timeout 30s`), the innermost command wins. You should pass this
+// config to Configure. This config has no effect on parsers.
+func AddTimeoutOption() Config {
+	return &addTimeoutOption{}
+}
+
+type addTimeoutOption struct{}
+
+func (c *addTimeoutOption) visit(p *parserWrapper) {
+	// nothing
+}
+
+// maybeAddTimeoutOption registers --timeout with the given parser when
+// the toplevel command root has been configured using AddTimeoutOption.
+func (p *CommandParser) maybeAddTimeoutOption(parser *parserWrapper, root *CommandParser) {
+	enabled := root.hasConfig(func(config Config) bool {
+		_, okay := config.(*addTimeoutOption)
+		return okay
+	})
+	if enabled {
+		parser.maybeAddTimeoutOption(&p.timeout)
+	}
+}
+
+// maybeAddTimeoutOption attempts to register --timeout. If the user has
+// already configured --timeout we'll just do nothing.
+func (p *parserWrapper) maybeAddTimeoutOption(timeout *time.Duration) bool {
+	var found bool
+	p.set.VisitAll(func(o getopt.Option) {
+		found = found || o.LongName() == "timeout"
+	})
+	if found {
+		return false
+	}
+	doc := "msgid:" + MsgTimeoutOption
+	p.set.FlagLong(timeout, "timeout", 0, doc)
+	p.docs["timeout"] = doc
+	p.defaults["timeout"] = time.Duration(0).String()
+	p.types["timeout"] = "time.Duration"
+	p.metavars["timeout"] = defaultMetavar(reflect.TypeOf(*timeout))
+	return true
+}
+
+// executionContext returns the context to pass to the RunFunc of the last
+// command in the chain, which depends on whether we've been configured using
+// CancelOnSignals and on the value of --timeout, along with the function
+// to call to release the resources associated with such a context.
+func executionContext(ctx context.Context, chain []*CommandParser) (context.Context, context.CancelFunc) {
+	var cancels []context.CancelFunc
+	cancelOnSignals := chain[0].hasConfig(func(config Config) bool {
+		_, okay := config.(*cancelOnSignals)
+		return okay
+	})
+	if cancelOnSignals {
+		var cancel context.CancelFunc
+		ctx, cancel = withSignalsCancel(ctx, chain[0].messages())
+		cancels = append(cancels, cancel)
+	}
+	var timeout time.Duration
+	for _, entry := range chain {
+		if entry.timeout > 0 {
+			timeout = entry.timeout
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		cancels = append(cancels, cancel)
+	}
+	return ctx, func() {
+		for idx := len(cancels) - 1; idx >= 0; idx-- {
+			cancels[idx]()
+		}
+	}
+}
+
+// withSignalsCancel returns a context that is cancelled when we receive SIGINT
+// or SIGTERM, along with a function to stop handling signals and to release
+// the resources associated with the context. When we receive a second signal,
+// we exit immediately using the conventional 128+signal exit code.
+func withSignalsCancel(ctx context.Context, m *messages) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, terminationSignals...)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			fmt.Fprintf(os.Stderr, "%s\n", m.sprintf(MsgInterrupted, sig))
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-ch:
+			os.Exit(signalExitCode(sig))
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(ch)
+		close(done)
+		cancel()
+	}
+}
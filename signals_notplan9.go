@@ -0,0 +1,21 @@
+//go:build !plan9
+// +build !plan9
+
+package getoptx
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminationSignals contains the signals that cause CancelOnSignals
+// to cancel the context passed to the selected command's RunFunc.
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalExitCode returns the conventional 128+signal exit code.
+func signalExitCode(sig os.Signal) int {
+	if s, okay := sig.(syscall.Signal); okay {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,14 @@
+package getoptx
+
+import "os"
+
+// terminationSignals contains the signals that cause CancelOnSignals
+// to cancel the context passed to the selected command's RunFunc. On
+// this platform, we can only portably handle os.Interrupt.
+var terminationSignals = []os.Signal{os.Interrupt}
+
+// signalExitCode returns the exit code to use after receiving the given
+// signal. On this platform, there is no 128+signal convention.
+func signalExitCode(sig os.Signal) int {
+	return 1
+}
@@ -0,0 +1,197 @@
+package getoptx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSignalsTestCLI is like newTestCLI but the RunFunc of `list` saves
+// the context it receives into ctx.
+func newSignalsTestCLI(options *testOptions, ctx *context.Context, config ...Config) *CommandParser {
+	cli := newTestCLI(options, config...)
+	testSubcommand(cli, "list").SetRun(func(runctx context.Context, sc *SelectedCommand) error {
+		*ctx = runctx
+		return nil
+	})
+	return cli
+}
+
+func TestAddTimeoutOption(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect time.Duration // zero means no deadline
+	}
+
+	testcases := []testcase{{
+		name:   "no timeout",
+		argv:   []string{"prog", "list"},
+		expect: 0,
+	}, {
+		name:   "toplevel timeout",
+		argv:   []string{"prog", "--timeout", "1h", "list"},
+		expect: time.Hour,
+	}, {
+		name:   "subcommand timeout",
+		argv:   []string{"prog", "list", "--timeout=2h"},
+		expect: 2 * time.Hour,
+	}, {
+		name:   "the innermost command wins",
+		argv:   []string{"prog", "--timeout", "3h", "list", "--timeout", "1h"},
+		expect: time.Hour,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				options testOptions
+				ctx     context.Context
+			)
+			cli := newSignalsTestCLI(&options, &ctx, AddTimeoutOption())
+			before := time.Now()
+			if err := cli.Execute(context.Background(), tc.argv); err != nil {
+				t.Fatal(err)
+			}
+			deadline, found := ctx.Deadline()
+			if found != (tc.expect > 0) {
+				t.Fatalf("unexpected deadline: %v %v", deadline, found)
+			}
+			if found && (deadline.Before(before.Add(tc.expect)) || deadline.After(time.Now().Add(tc.expect))) {
+				t.Fatalf("unexpected deadline: %v", deadline)
+			}
+			if found && !errors.Is(ctx.Err(), context.Canceled) {
+				t.Fatalf("expected the context to be canceled after Execute, got %v", ctx.Err())
+			}
+		})
+	}
+
+	t.Run("expiring timeout", func(t *testing.T) {
+		var options testOptions
+		cli := newTestCLI(&options, AddTimeoutOption())
+		testSubcommand(cli, "list").SetRun(func(ctx context.Context, sc *SelectedCommand) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		err := cli.Execute(context.Background(), []string{"prog", "list", "--timeout", "10ms"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		t.Setenv("COLUMNS", "80")
+		var (
+			options testOptions
+			ctx     context.Context
+		)
+		cli := newSignalsTestCLI(&options, &ctx, AddTimeoutOption())
+		stdout := captureStdout(t)
+		if err := cli.Execute(context.Background(), []string{"prog", "list", "--help"}); err != nil {
+			t.Fatal(err)
+		}
+		expect := "\n      --timeout duration\n" +
+			"             Stops the command after the given duration (e.g., 30s).\n"
+		if output := stdout(); strings.Count(output, expect) != 2 {
+			t.Fatalf("expected --timeout for prog and list inside:\n%s", output)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		captureStderr(t)
+		var (
+			options testOptions
+			ctx     context.Context
+		)
+		cli := newSignalsTestCLI(&options, &ctx)
+		err := cli.Execute(context.Background(), []string{"prog", "list", "--timeout", "1s"})
+		var unknown *UnknownOptionError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected *UnknownOptionError, got %T", err)
+		}
+	})
+}
+
+func TestExecuteTwice(t *testing.T) {
+	withProgramName(t, "prog")
+	captureStdout(t)
+	var (
+		options testOptions
+		ctx     context.Context
+	)
+	cli := newSignalsTestCLI(&options, &ctx, AddTimeoutOption())
+
+	type step struct {
+		argv     []string
+		run      bool
+		deadline bool
+	}
+
+	steps := []step{{
+		argv:     []string{"prog", "--timeout", "1h", "list"},
+		run:      true,
+		deadline: true,
+	}, {
+		argv: []string{"prog", "list", "--help=json"},
+		run:  false,
+	}, {
+		argv:     []string{"prog", "list"},
+		run:      true,
+		deadline: false,
+	}}
+
+	for idx, s := range steps {
+		ctx = nil
+		if err := cli.Execute(context.Background(), s.argv); err != nil {
+			t.Fatal(err)
+		}
+		if (ctx != nil) != s.run {
+			t.Fatalf("step %d: expected run to be %v", idx, s.run)
+		}
+		if ctx == nil {
+			continue
+		}
+		if _, found := ctx.Deadline(); found != s.deadline {
+			t.Fatalf("step %d: expected deadline to be %v", idx, s.deadline)
+		}
+	}
+}
+
+func TestCancelOnSignals(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("cannot send os.Interrupt to ourselves on " + runtime.GOOS)
+	}
+	withProgramName(t, "prog")
+	stderr := captureStderr(t)
+	var options testOptions
+	cli := newTestCLI(&options, CancelOnSignals())
+	testSubcommand(cli, "list").SetRun(func(ctx context.Context, sc *SelectedCommand) error {
+		proc, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return err
+		}
+		if err := proc.Signal(os.Interrupt); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return errors.New("the context has not been canceled")
+		}
+	})
+	err := cli.Execute(context.Background(), []string{"prog", "list"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	expect := "received interrupt, stopping (send it again to exit immediately)\n"
+	if output := stderr(); output != expect {
+		t.Fatalf("expected %q, got %q", expect, output)
+	}
+}
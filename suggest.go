@@ -0,0 +1,114 @@
+package getoptx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// suggest returns the candidates that are close enough to name according
+// to the edit distance, sorted by increasing distance and then by name.
+func suggest(name string, candidates []string) []string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	type entry struct {
+		candidate string
+		distance  int
+	}
+	var entries []entry
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d <= maxDistance {
+			entries = append(entries, entry{candidate: candidate, distance: d})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].distance != entries[j].distance {
+			return entries[i].distance < entries[j].distance
+		}
+		return entries[i].candidate < entries[j].candidate
+	})
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.candidate)
+	}
+	return out
+}
+
+// editDistance computes the optimal string alignment distance between a and
+// b, i.e., the Levenshtein distance where swapping two adjacent characters
+// counts as a single edit. This is the most common kind of typo.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= len(rb); j++ {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// minInt returns the minimum between a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// printSuggestions prints "did you mean" suggestions for the given error
+// on the given writer. This function does nothing if the error is not
+// an *UnknownOptionError or an *UnknownSubcommandError.
+func printSuggestions(w io.Writer, err error, m *messages) {
+	var (
+		uoe *UnknownOptionError
+		use *UnknownSubcommandError
+	)
+	switch {
+	case errors.As(err, &uoe):
+		printDidYouMean(w, uoe.Suggestions, m)
+	case errors.As(err, &use) && len(use.Suggestions) > 0:
+		printDidYouMean(w, use.Suggestions, m)
+	case errors.As(err, &use) && len(use.Valid) > 0:
+		fmt.Fprintf(w, "\n%s\n", m.sprintf(MsgValidSubcommands, quoteAndJoin(use.Valid)))
+	}
+}
+
+// printDidYouMean is an utility function for printing suggestions.
+func printDidYouMean(w io.Writer, suggestions []string, m *messages) {
+	switch len(suggestions) {
+	case 0:
+		// nothing
+	case 1:
+		fmt.Fprintf(w, "\n%s\n", m.sprintf(MsgDidYouMean, suggestions[0]))
+	default:
+		fmt.Fprintf(w, "\n%s\n", m.sprintf(MsgDidYouMeanOneOf, quoteAndJoin(suggestions)))
+	}
+}
+
+// quoteAndJoin quotes each entry using single quotes and joins them.
+func quoteAndJoin(entries []string) string {
+	var quoted []string
+	for _, e := range entries {
+		quoted = append(quoted, "'"+e+"'")
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,116 @@
+package getoptx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	type testcase struct {
+		a, b   string
+		expect int
+	}
+
+	testcases := []testcase{
+		{a: "", b: "", expect: 0},
+		{a: "input", b: "input", expect: 0},
+		{a: "", b: "abc", expect: 3},
+		{a: "inptu", b: "input", expect: 1}, // adjacent swap
+		{a: "inpt", b: "input", expect: 1},
+		{a: "verbose", b: "version", expect: 4},
+		{a: "kitten", b: "sitting", expect: 3},
+	}
+
+	for _, tc := range testcases {
+		if got := editDistance(tc.a, tc.b); got != tc.expect {
+			t.Errorf("editDistance(%q, %q): expected %d, got %d", tc.a, tc.b, tc.expect, got)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"--input", "--input-file", "--verbose", "--version"}
+
+	type testcase struct {
+		name   string
+		expect []string
+	}
+
+	testcases := []testcase{
+		{name: "--inptu", expect: []string{"--input"}},
+		{name: "--verison", expect: []string{"--version", "--verbose"}},
+		{name: "--vrebose", expect: []string{"--verbose"}},
+		{name: "--nonexistent", expect: nil},
+	}
+
+	for _, tc := range testcases {
+		if got := suggest(tc.name, candidates); !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("suggest(%q): expected %v, got %v", tc.name, tc.expect, got)
+		}
+	}
+}
+
+func TestPrintSuggestions(t *testing.T) {
+	withProgramName(t, "prog")
+
+	type options struct {
+		Global struct {
+			Verbose bool `doc:"run in verbose mode"`
+			Version bool `doc:"print the version"`
+		}
+		Run struct{}
+		Rm  struct{}
+	}
+
+	type testcase struct {
+		name   string
+		argv   []string
+		expect string
+	}
+
+	testcases := []testcase{{
+		name: "one option",
+		argv: []string{"prog", "--vrebose"},
+		expect: "prog: unknown option: --vrebose. See 'prog --help'.\n" +
+			"\nDid you mean '--verbose'?\n",
+	}, {
+		name: "many options",
+		argv: []string{"prog", "--verison"},
+		expect: "prog: unknown option: --verison. See 'prog --help'.\n" +
+			"\nDid you mean one of '--version', '--verbose'?\n",
+	}, {
+		name:   "no similar options",
+		argv:   []string{"prog", "--nonexistent"},
+		expect: "prog: unknown option: --nonexistent. See 'prog --help'.\n",
+	}, {
+		name: "subcommand",
+		argv: []string{"prog", "rnu"},
+		expect: "prog: no such subcommand: 'rnu'. See 'prog --help'.\n" +
+			"\nDid you mean 'run'?\n",
+	}, {
+		name: "no similar subcommands",
+		argv: []string{"prog", "nonexistent"},
+		expect: "prog: no such subcommand: 'nonexistent'. See 'prog --help'.\n" +
+			"\nValid subcommands are: 'help', 'rm', 'run'.\n",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := captureStderr(t)
+			var opts options
+			cli := Command(
+				"Test program",
+				&opts.Global,
+				LeafSubcommand("run", "Runs measurements", &opts.Run),
+				LeafSubcommand("rm", "Removes measurements", &opts.Rm),
+			)
+			cli.Configure(SetColorMode(ColorNever))
+			if _, err := cli.Getopt(tc.argv); err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := stderr(); got != tc.expect {
+				t.Fatalf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
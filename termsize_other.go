@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package getoptx
+
+import "os"
+
+// terminalWidth returns the width of the terminal attached to the
+// given file or zero if the file is not attached to a terminal. On
+// this platform we do not know how to query the terminal, hence we
+// always return zero and rely on the COLUMNS environment variable.
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,162 @@
+package getoptx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteZshCompletion writes on w a zsh completion script for the given
+// parser, which must have been created using NewParser or MustNewParser.
+func WriteZshCompletion(w io.Writer, parser Parser) error {
+	root, err := newParserCompletionTree(parser)
+	if err != nil {
+		return err
+	}
+	return writeZshCompletion(w, root)
+}
+
+// WriteZshCompletion writes on w a zsh completion script for the whole
+// commands tree based on `_arguments`. Unlike bash, zsh shows the options
+// documentation and the subcommands descriptions inline. Like bash, the
+// script completes the values of the options implementing Completer by
+// running `prog __complete`. You should call this method on the toplevel
+// command. To use the script, save it as `_prog` (where `prog` is the
+// program name) inside a directory in $fpath.
+func (p *CommandParser) WriteZshCompletion(w io.Writer) error {
+	root, err := p.newCompletionTree()
+	if err != nil {
+		return err
+	}
+	return writeZshCompletion(w, root)
+}
+
+// writeZshCompletion writes the zsh completion script for the given tree.
+func writeZshCompletion(w io.Writer, root *completionNode) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#compdef %s\n", root.name())
+	var dynamic bool
+	for _, node := range root.flatten() {
+		dynamic = dynamic || len(node.completerOptionWords()) > 0
+	}
+	if dynamic {
+		sb.WriteString("\n")
+		writeZshDynamicFunction(&sb, root)
+	}
+	for _, node := range root.flatten() {
+		sb.WriteString("\n")
+		writeZshFunction(&sb, node, root)
+	}
+	fmt.Fprintf(&sb, "\n%s \"$@\"\n", zshFunctionName(root))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// zshFunctionName returns the name of the function completing the given node.
+func zshFunctionName(node *completionNode) string {
+	return "_" + shellIdentifier(strings.Join(node.names, "_"))
+}
+
+// zshDynamicFunctionName returns the name of the function that completes
+// the values of the options implementing Completer using `prog __complete`.
+func zshDynamicFunctionName(root *completionNode) string {
+	return zshFunctionName(root) + "__complete"
+}
+
+// writeZshDynamicFunction writes the function that completes the values
+// of the options implementing Completer using `prog __complete`. Since
+// `_arguments` rewrites $words when dispatching to subcommands, we split
+// the command line up to the cursor to obtain the original words.
+func writeZshDynamicFunction(sb *strings.Builder, root *completionNode) {
+	fmt.Fprintf(sb, "%s() {\n", zshDynamicFunctionName(root))
+	sb.WriteString("\tlocal -a args candidates\n")
+	sb.WriteString("\targs=(\"${(@Q)${(z)LBUFFER}}\")\n")
+	sb.WriteString("\t[[ $LBUFFER == *[[:space:]] ]] && args+=('')\n")
+	sb.WriteString("\tcandidates=(${(f)\"$(\"${args[1]}\" __complete \"${(@)args[2,-1]}\" 2>/dev/null)\"})\n")
+	sb.WriteString("\t[[ ${args[-1]} == --*=* ]] && candidates=(${candidates#*=})\n")
+	sb.WriteString("\tcompadd -a candidates\n")
+	sb.WriteString("}\n")
+}
+
+// writeZshFunction writes the function completing the given node.
+func writeZshFunction(sb *strings.Builder, node, root *completionNode) {
+	fmt.Fprintf(sb, "%s() {\n", zshFunctionName(node))
+	sb.WriteString("\tlocal curcontext=\"$curcontext\" context state state_descr line\n")
+	sb.WriteString("\ttypeset -A opt_args\n")
+	sb.WriteString("\t_arguments -S -C")
+	for _, o := range uniqueOptions(node.allOptions()) {
+		action := " " // no completion
+		if node.completers[o.Long] {
+			action = zshDynamicFunctionName(root)
+		}
+		fmt.Fprintf(sb, " \\\n\t\t%s", zshOptionSpec(o, action))
+	}
+	switch {
+	case len(node.children) > 0:
+		sb.WriteString(" \\\n\t\t'1: :->subcommand'")
+		sb.WriteString(" \\\n\t\t'*:: :->args'")
+	case node.positional:
+		sb.WriteString(" \\\n\t\t'*:argument:_files'")
+	}
+	sb.WriteString("\n")
+	if len(node.children) > 0 {
+		sb.WriteString("\tcase $state in\n")
+		sb.WriteString("\tsubcommand)\n")
+		sb.WriteString("\t\tlocal -a subcommands\n")
+		sb.WriteString("\t\tsubcommands=(\n")
+		for _, child := range node.children {
+			fmt.Fprintf(sb, "\t\t\t%s\n", shellQuote(zshEscape(child.name(), ":")+":"+child.description))
+		}
+		sb.WriteString("\t\t)\n")
+		sb.WriteString("\t\t_describe -t commands 'subcommand' subcommands\n")
+		sb.WriteString("\t\t;;\n")
+		sb.WriteString("\targs)\n")
+		sb.WriteString("\t\tcase $line[1] in\n")
+		for _, child := range node.children {
+			patterns := []string{shellQuote(child.name())}
+			for _, alias := range child.aliases {
+				patterns = append(patterns, shellQuote(alias))
+			}
+			fmt.Fprintf(sb, "\t\t%s)\n\t\t\t%s\n\t\t\t;;\n", strings.Join(patterns, "|"), zshFunctionName(child))
+		}
+		sb.WriteString("\t\tesac\n")
+		sb.WriteString("\t\t;;\n")
+		sb.WriteString("\tesac\n")
+	}
+	sb.WriteString("}\n")
+}
+
+// zshOptionSpec returns the `_arguments` spec for the given option, where
+// action is the `_arguments` action completing the option's value.
+func zshOptionSpec(o HelpOption, action string) string {
+	description := "[" + zshEscape(o.Doc, "[]") + "]"
+	var value string
+	if !o.IsFlag {
+		value = ":" + zshEscape(o.Metavar, ":") + ":" + action
+	}
+	long := "--" + o.Long
+	if !o.IsFlag {
+		long += "=" // accept both `--name=value` and `--name value`
+	}
+	if o.Short == "" {
+		return shellQuote(long + description + value)
+	}
+	short := "-" + o.Short
+	if !o.IsFlag {
+		short += "+" // accept both `-nvalue` and `-n value`
+	}
+	return fmt.Sprintf("{%s,%s}%s", shellQuote(short), shellQuote(long), shellQuote(description+value))
+}
+
+// zshEscape escapes the given special characters and backslashes
+// using a backslash, as required by `_arguments` and `_describe`.
+func zshEscape(s, special string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(special, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
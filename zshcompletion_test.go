@@ -0,0 +1,101 @@
+package getoptx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteZshCompletionParser(t *testing.T) {
+	var options struct {
+		Input   string `doc:"add URL to measure" metavar:"URL"`
+		Verbose bool   `doc:"run in verbose mode" short:"v"`
+	}
+	parser, err := NewParser(&options, SetProgramName("/usr/bin/prog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb bytes.Buffer
+	if err := WriteZshCompletion(&sb, parser); err != nil {
+		t.Fatal(err)
+	}
+	expect := `#compdef prog
+
+_prog() {
+	local curcontext="$curcontext" context state state_descr line
+	typeset -A opt_args
+	_arguments -S -C \
+		'--input=[add URL to measure]:URL: ' \
+		{'-v','--verbose'}'[run in verbose mode]' \
+		'*:argument:_files'
+}
+
+_prog "$@"
+`
+	if sb.String() != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, sb.String())
+	}
+}
+
+func TestWriteZshCompletion(t *testing.T) {
+	withProgramName(t, "/usr/bin/prog")
+	var options testOptions
+	cli := newTestCLI(&options)
+	var sb bytes.Buffer
+	if err := cli.WriteZshCompletion(&sb); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+	for _, expect := range []string{
+		"#compdef prog\n",
+		"\n_prog() {\n",
+		"\t\t'1: :->subcommand' \\\n\t\t'*:: :->args'\n",
+		"\t\tsubcommands=(\n\t\t\t'list:Lists network measurements'\n\t\t\t'run:Runs network measurements'\n\t\t)\n",
+		"\t\t'run')\n\t\t\t_prog_run\n\t\t\t;;\n",
+		"\n_prog_run_websites() {\n",
+		"\t\t{'-3','--force-http-3'}'[forces using HTTP3]' \\\n",
+		"\t\t'--id=[ID of the result to show]:value:_prog__complete' \\\n\t\t'*:argument:_files'\n",
+		"\n_prog \"$@\"\n",
+	} {
+		if !strings.Contains(script, expect) {
+			t.Fatalf("cannot find %q inside:\n%s", expect, script)
+		}
+	}
+}
+
+func TestZshOptionSpec(t *testing.T) {
+	type testcase struct {
+		name   string
+		option HelpOption
+		action string
+		expect string
+	}
+
+	testcases := []testcase{{
+		name:   "long flag",
+		option: HelpOption{Long: "batch", Doc: "emit JSON messages", IsFlag: true},
+		expect: `'--batch[emit JSON messages]'`,
+	}, {
+		name:   "short and long flag",
+		option: HelpOption{Long: "verbose", Short: "v", Doc: "verbose", IsFlag: true},
+		expect: `{'-v','--verbose'}'[verbose]'`,
+	}, {
+		name:   "option with value",
+		option: HelpOption{Long: "input", Short: "i", Doc: "the input", Metavar: "URL"},
+		action: " ",
+		expect: `{'-i+','--input='}'[the input]:URL: '`,
+	}, {
+		name:   "escaping",
+		option: HelpOption{Long: "list", Doc: "set [a]\\b or don't", Metavar: "a:b"},
+		action: "_files",
+		expect: `'--list=[set \[a\]\\b or don'\''t]:a\:b:_files'`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := zshOptionSpec(tc.option, tc.action); got != tc.expect {
+				t.Fatalf("expected %s, got %s", tc.expect, got)
+			}
+		})
+	}
+}